@@ -0,0 +1,70 @@
+package gpm
+
+import (
+	"strings"
+)
+
+const ANNOTATION = '@'
+
+// Annotations are the machine-readable `@name value` pairs found in the
+// comments of a property, e.g. `# @type int @min 1 @secret`. Flags
+// without a value map to "".
+type Annotations map[string]string
+
+// Has reports whether the annotation name is present.
+func (a Annotations) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// Get returns the value of the annotation name.
+func (a Annotations) Get(name string) (string, bool) {
+	v, ok := a[name]
+	return v, ok
+}
+
+// ParseAnnotations extracts the annotations of a comment. Each word
+// starting with '@' starts an annotation, the words following it up to
+// the next annotation are its value.
+func ParseAnnotations(comment string) Annotations {
+	annotations := Annotations{}
+	name := ""
+	var value []string
+	flush := func() {
+		if name != "" {
+			annotations[name] = strings.Join(value, " ")
+		}
+		name = ""
+		value = value[:0]
+	}
+	for _, word := range strings.Fields(comment) {
+		if len(word) > 1 && word[0] == ANNOTATION {
+			flush()
+			name = word[1:]
+			continue
+		}
+		if name != "" {
+			value = append(value, word)
+		}
+	}
+	flush()
+	return annotations
+}
+
+// Annotations returns the annotations found in the comment lines directly
+// above the property and in its inline comment. The inline comment wins
+// when both define the same annotation.
+func (p *Property) Annotations() Annotations {
+	annotations := Annotations{}
+	for _, line := range strings.Split(p.doc, "\n") {
+		for k, v := range ParseAnnotations(line) {
+			annotations[k] = v
+		}
+	}
+	if p.hasComment && !p.IsCommentOnly() {
+		for k, v := range ParseAnnotations(p.comment) {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
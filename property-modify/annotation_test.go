@@ -0,0 +1,65 @@
+package gpm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		want    Annotations
+	}{
+		{"empty", "", Annotations{}},
+		{"no annotation", "just a comment", Annotations{}},
+		{"flag", "@secret", Annotations{"secret": ""}},
+		{"values", "@type int @min 1 @secret", Annotations{"type": "int", "min": "1", "secret": ""}},
+		{"value of several words", "@owner build team", Annotations{"owner": "build team"}},
+		{"text before", "the port @type int", Annotations{"type": "int"}},
+		{"lone at sign", "mail @ home @type int", Annotations{"type": "int"}},
+		{"repeated", "@min 1 @min 2", Annotations{"min": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAnnotations(tt.comment); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAnnotations(%q) = %v, want %v", tt.comment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropertyAnnotations(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		key   string
+		want  Annotations
+	}{
+		{"none", "port=80\n", "port", Annotations{}},
+		{"comment above", "# @type int\nport=80\n", "port", Annotations{"type": "int"}},
+		{"several comment lines", "# @type int\n# @min 1\nport=80\n", "port", Annotations{"type": "int", "min": "1"}},
+		{"inline comment", "port=80 # @type int\n", "port", Annotations{"type": "int"}},
+		{"inline wins", "# @type string\nport=80 # @type int\n", "port", Annotations{"type": "int"}},
+		{"empty value", "# @secret\npassword=\n", "password", Annotations{"secret": ""}},
+		{"after a blank line", "# @secret\n\npassword=x\n", "password", Annotations{}},
+		{"duplicate key", "# @type int\nport=80\nport=81\n", "port", Annotations{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Annotations
+			for _, p := range doc.Props() {
+				if p.Key() == tt.key {
+					got = p.Annotations()
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Annotations of the last %s = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package gpm
+
+import (
+	"math/rand"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Anonymize returns data, a property file, with every word of its keys,
+// values and comments replaced by a placeholder of the same length and
+// shape: letters by letters of the same case, digits by digits. Anything
+// else is kept, so that the copy has the structure of the file and parses
+// the same way: separators, whitespace, comment markers, line endings,
+// escapes, the names of @annotations and the length of every line in
+// characters. A word is always replaced by the same placeholder and two
+// words never share one, so that keys set twice stay duplicates. seed
+// picks the placeholders.
+func Anonymize(data []byte, seed int64) []byte {
+	a := anonymizer{
+		rnd:    rand.New(rand.NewSource(seed)),
+		tokens: make(map[string]string),
+		used:   make(map[string]bool),
+	}
+	runes := []rune(string(data))
+	var sb strings.Builder
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ESCAPE && i+1 < len(runes):
+			n := 2
+			if _, ok := hexCode(runes, i+2); runes[i+1] == 'u' && ok {
+				n = 6
+			}
+			sb.WriteString(string(runes[i : i+n]))
+			i += n
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			if i > 0 && runes[i-1] == ANNOTATION {
+				sb.WriteString(word)
+			} else {
+				sb.WriteString(a.token(runes[i:j]))
+			}
+			i = j
+		default:
+			sb.WriteRune(r)
+			i++
+		}
+	}
+	return []byte(sb.String())
+}
+
+// isWordRune reports whether Anonymize replaces r. Bytes that aren't
+// UTF-8, e.g. of an ISO-8859-1 file, are replaced too.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == utf8.RuneError
+}
+
+// anonymizer hands out the placeholders of Anonymize.
+type anonymizer struct {
+	rnd *rand.Rand
+	// tokens are the placeholders of the words, used the placeholders
+	// handed out
+	tokens map[string]string
+	used   map[string]bool
+}
+
+// token returns the placeholder of word, picking a new one for a new
+// word. A short word may have to share one when there are more words of
+// its shape than placeholders.
+func (a *anonymizer) token(word []rune) string {
+	if token, ok := a.tokens[string(word)]; ok {
+		return token
+	}
+	var token string
+	for attempt := 0; attempt < 100; attempt++ {
+		placeholder := make([]rune, len(word))
+		for i, r := range word {
+			switch {
+			case unicode.IsDigit(r):
+				placeholder[i] = '0' + rune(a.rnd.Intn(10))
+			case unicode.IsUpper(r):
+				placeholder[i] = 'A' + rune(a.rnd.Intn(26))
+			default:
+				placeholder[i] = 'a' + rune(a.rnd.Intn(26))
+			}
+		}
+		token = string(placeholder)
+		if !a.used[token] && token != string(word) {
+			break
+		}
+	}
+	a.tokens[string(word)] = token
+	a.used[token] = true
+	return token
+}
@@ -0,0 +1,111 @@
+package gpm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ASCII_ESCAPE writes the characters outside of ASCII as \uXXXX
+	ASCII_ESCAPE = "escape"
+	// ASCII_TRANSLITERATE replaces them by ASCII look-alikes, "é" by "e"
+	// or "ß" by "ss", and escapes the ones without any
+	ASCII_TRANSLITERATE = "transliterate"
+)
+
+// transliterations maps characters to ASCII, the characters of each key
+// to its value.
+var transliterations = map[string]string{
+	"ÀÁÂÃÄÅĀĂĄ": "A", "àáâãäåāăą": "a", "ÇĆĈĊČ": "C", "çćĉċč": "c",
+	"ĎĐÐ": "D", "ďđð": "d", "ÈÉÊËĒĔĖĘĚ": "E", "èéêëēĕėęě": "e",
+	"ĜĞĠĢ": "G", "ĝğġģ": "g", "ĤĦ": "H", "ĥħ": "h", "ÌÍÎÏĨĪĬĮİ": "I",
+	"ìíîïĩīĭįı": "i", "Ĵ": "J", "ĵ": "j", "Ķ": "K", "ķ": "k",
+	"ĹĻĽĿŁ": "L", "ĺļľŀł": "l", "ÑŃŅŇ": "N", "ñńņň": "n",
+	"ÒÓÔÕÖØŌŎŐ": "O", "òóôõöøōŏő": "o", "ŔŖŘ": "R", "ŕŗř": "r",
+	"ŚŜŞŠ": "S", "śŝşš": "s", "ŢŤŦ": "T", "ţťŧ": "t",
+	"ÙÚÛÜŨŪŬŮŰŲ": "U", "ùúûüũūŭůűų": "u", "Ŵ": "W", "ŵ": "w",
+	"ÝŶŸ": "Y", "ýÿŷ": "y", "ŹŻŽ": "Z", "źżž": "z",
+	"Æ": "AE", "æ": "ae", "Œ": "OE", "œ": "oe", "ß": "ss", "Þ": "TH", "þ": "th",
+	"‘’‚′": "'", "“”„″": "\"", "‐‑‒–—―−": "-", "…": "...", "\u00A0\u2007\u202F": " ",
+	"«": "<<", "»": ">>", "×": "x", "÷": "/", "•·": "*", "€": "EUR",
+	"©": "(c)", "®": "(R)", "™": "TM",
+}
+
+var transliterationTable = func() map[rune]string {
+	table := make(map[rune]string)
+	for from, to := range transliterations {
+		for _, r := range from {
+			table[r] = to
+		}
+	}
+	return table
+}()
+
+// Transliteration is a line changed by ToASCII.
+type Transliteration struct {
+	Line int
+	// Key is "" for a comment line
+	Key    string
+	Before string
+	After  string
+}
+
+// toASCII returns s with the characters outside of ASCII replaced as mode
+// says.
+func toASCII(s, mode string) string {
+	if isASCII(s) {
+		return s
+	}
+	if mode != ASCII_TRANSLITERATE {
+		return EncodeUnicode(s)
+	}
+	var sb strings.Builder
+	for i, r := range s {
+		switch to, ok := transliterationTable[r]; {
+		case r < utf8.RuneSelf:
+			sb.WriteRune(r)
+		case ok:
+			sb.WriteString(to)
+		case r == utf8.RuneError && !strings.HasPrefix(s[i:], string(utf8.RuneError)):
+			// not UTF-8, e.g. a file read as UTF-8 that isn't
+			sb.WriteByte('?')
+		default:
+			sb.WriteString(EncodeUnicode(string(r)))
+		}
+	}
+	return sb.String()
+}
+
+// ToASCII rewrites the keys, values and comments of every line that has
+// characters outside of ASCII, with mode ASCII_ESCAPE or
+// ASCII_TRANSLITERATE, and drops the BOM, so that Save writes pure ASCII
+// for systems that reject anything else. It returns the changed lines.
+func (m *Modifier) ToASCII(mode string) []Transliteration {
+	m.number()
+	m.bom = false
+	var changed []Transliteration
+	for e := m.head; e != nil; e = e.next {
+		text := e.text()
+		if isASCII(text) {
+			continue
+		}
+		if e.key != "" {
+			key := toASCII(e.key, mode)
+			if key != e.key {
+				m.unindex(e)
+				e.key = key
+				m.index[key] = e
+			}
+		}
+		e.value = toASCII(e.value, mode)
+		e.comment = toASCII(e.comment, mode)
+		e.original = nil
+		changed = append(changed, Transliteration{
+			Line:   e.lineNum,
+			Key:    e.key,
+			Before: text,
+			After:  e.String(),
+		})
+	}
+	return changed
+}
@@ -0,0 +1,73 @@
+package gpm
+
+const (
+	KIND_PROPERTY = "property"
+	KIND_COMMENT  = "comment"
+	KIND_BLANK    = "blank"
+	// KIND_RAW is a line that is neither a property nor a comment, kept
+	// verbatim
+	KIND_RAW = "raw"
+)
+
+// ASTEntry is the parsed model of one line, as dumped by -dump-ast.
+type ASTEntry struct {
+	Line      int    `json:"line"`
+	Offset    int    `json:"offset"`
+	Kind      string `json:"kind"`
+	Key       string `json:"key,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Separator string `json:"separator,omitempty"`
+	// Quote is the quote around the value, see WithQuotedValues
+	Quote      string `json:"quote,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	HasComment bool   `json:"hasComment"`
+	// Disabled is set on a comment that comments out a property, see
+	// Property.Disabled
+	Disabled bool   `json:"disabled,omitempty"`
+	Doc      string `json:"doc,omitempty"`
+	Raw      string `json:"raw"`
+}
+
+// Kind returns KIND_PROPERTY, KIND_COMMENT, KIND_BLANK or KIND_RAW.
+func (p *Property) Kind() string {
+	switch {
+	case p.IsRaw():
+		return KIND_RAW
+	case p.IsBlank():
+		return KIND_BLANK
+	case p.IsCommentOnly():
+		return KIND_COMMENT
+	}
+	return KIND_PROPERTY
+}
+
+// AST returns the model of every parsed line.
+func (p *Parser) AST() []ASTEntry {
+	return astOf(p.props)
+}
+
+func astOf(props []Property) []ASTEntry {
+	entries := make([]ASTEntry, 0, len(props))
+	for _, prop := range props {
+		entry := ASTEntry{
+			Line:       prop.lineNum,
+			Offset:     prop.offset,
+			Kind:       prop.Kind(),
+			Key:        prop.key,
+			Value:      prop.value,
+			Quote:      prop.quote,
+			Comment:    prop.comment,
+			HasComment: prop.hasComment,
+			Doc:        prop.doc,
+			Raw:        prop.raw,
+		}
+		if entry.Kind == KIND_PROPERTY {
+			entry.Separator = prop.Separator()
+		}
+		if entry.Kind == KIND_COMMENT {
+			entry.Disabled = prop.IsDisabled()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,77 @@
+package gpm
+
+import "fmt"
+
+// IsBlank reports whether p is a blank line, of Kind KIND_BLANK: a line
+// without a key or a comment, empty or only whitespace.
+func (p *Property) IsBlank() bool {
+	return p.IsEmpty()
+}
+
+// InsertBlankLineBefore separates key from the lines above it with a blank
+// line, inserted above the comment lines directly above key. It does
+// nothing if there already is one, or if there are no lines above.
+func (m *Modifier) InsertBlankLineBefore(key string) error {
+	e, ok := m.index[key]
+	if !ok {
+		return fmt.Errorf("key %q not found", key)
+	}
+	first := e
+	if block := m.commentBlock(e); len(block) > 0 {
+		first = block[len(block)-1]
+	}
+	if first.prev == nil || first.prev.IsBlank() {
+		return nil
+	}
+	m.insertBefore(first, NewBlank())
+	return nil
+}
+
+// CollapseBlankLines shortens the runs of more than max consecutive blank
+// lines to max lines, 0 removing every blank line and a negative max
+// keeping them all. It returns the number of lines removed.
+func (m *Modifier) CollapseBlankLines(max int) int {
+	if max < 0 {
+		return 0
+	}
+	removed := 0
+	blanks := 0
+	lines := m.lines()
+	props := lines[:0]
+	for _, p := range lines {
+		if !p.IsBlank() {
+			blanks = 0
+			props = append(props, p)
+			continue
+		}
+		blanks++
+		if blanks > max {
+			removed++
+			continue
+		}
+		props = append(props, p)
+	}
+	if removed > 0 {
+		m.setLines(props)
+	}
+	return removed
+}
+
+// WithoutBlankLines leaves the blank lines out of the saved file, without
+// removing them from the Modifier.
+func WithoutBlankLines() SaveOption {
+	return func(c *saveConfig) {
+		c.noBlankLines = true
+	}
+}
+
+// withoutBlankLines returns the lines of props that aren't blank.
+func withoutBlankLines(props []Property) []Property {
+	kept := make([]Property, 0, len(props))
+	for _, p := range props {
+		if !p.IsBlank() {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,129 @@
+// Package bridge is the string in, string out core shared by the c-shared
+// library in capi and the WebAssembly module in wasm, so that callers on
+// the JVM or in a browser get the exact parse, modify and save semantics
+// of the gpm command.
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gpm"
+	"gpm/wire"
+	"strings"
+)
+
+const (
+	OP_TYPE_SET    = "set"
+	OP_TYPE_RM     = "rm"
+	OP_TYPE_RENAME = "rename"
+)
+
+// Operation is one change to apply, in the JSON form of the gpm serve
+// and -ops-stdin operations.
+type Operation struct {
+	Type    string `json:"type"` // "set", "rm" or "rename"
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	NewKey  string `json:"newKey,omitempty"`
+}
+
+// Apply applies the JSON array of operations opsJSON to the property file
+// text and returns the saved result.
+func Apply(text, opsJSON string) (string, error) {
+	var operations []Operation
+	if err := json.Unmarshal([]byte(opsJSON), &operations); err != nil {
+		return "", fmt.Errorf("invalid operations: %w", err)
+	}
+	doc, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	for _, selected := range operations {
+		expanded, err := expand(doc, selected)
+		if err != nil {
+			return "", err
+		}
+		for _, op := range expanded {
+			if err := apply(doc, op); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Save(&buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// expand returns the operations on the keys selected by the key of op,
+// which may be a glob: or re: pattern like in the gpm command.
+func expand(doc *gpm.Document, op Operation) ([]Operation, error) {
+	k, err := gpm.CompileKeyMatcher(op.Key)
+	if err != nil {
+		return nil, err
+	}
+	if k.IsExact() {
+		return []Operation{op}, nil
+	}
+	var operations []Operation
+	for _, key := range doc.MatchingKeys(k) {
+		o := op
+		o.Key = key
+		if op.Type == OP_TYPE_RENAME {
+			o.NewKey = k.Rename(key, op.NewKey)
+		}
+		operations = append(operations, o)
+	}
+	return operations, nil
+}
+
+func apply(doc *gpm.Document, op Operation) error {
+	switch op.Type {
+	case OP_TYPE_SET:
+		var comment *string
+		if op.Comment != "" {
+			comment = &op.Comment
+		}
+		return doc.SetProperty(op.Key, op.Value, comment)
+	case OP_TYPE_RM:
+		doc.RemoveProperty(op.Key)
+	case OP_TYPE_RENAME:
+		return doc.RenameKey(op.Key, op.NewKey, false)
+	default:
+		return fmt.Errorf("unknown operation: %s", op.Type)
+	}
+	return nil
+}
+
+// parse parses text like the gpm command parses its input without flags.
+func parse(text string) (*gpm.Document, error) {
+	return gpm.Parse(strings.NewReader(text), DefaultOptions().ParserOptions()...)
+}
+
+// Get returns the value of key in the property file text.
+func Get(text, key string) (value string, ok bool, err error) {
+	doc, err := parse(text)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok = doc.Get(key)
+	return value, ok, nil
+}
+
+// AST returns the -dump-ast json model of the property file text.
+func AST(text string) (string, error) {
+	doc, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := wire.Write(&buf, wire.NewAST(doc.AST())); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
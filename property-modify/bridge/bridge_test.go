@@ -0,0 +1,19 @@
+package bridge
+
+import "testing"
+
+func TestParsesLikeTheCommand(t *testing.T) {
+	// ISO-8859-1, read as such by the command's -encoding auto
+	value, ok, err := Get("greeting=caf\xe9\n", "greeting")
+	if err != nil || !ok || value != "café" {
+		t.Errorf("Get = %q, %v, %v, want %q", value, ok, err, "café")
+	}
+
+	out, err := Apply("greeting=caf\xe9\n", `[{"type":"set","key":"color","value":"#FF"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "greeting=caf\xe9\ncolor=\\#FF\n"; out != want {
+		t.Errorf("Apply = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import "gpm"
+
+// Options are the parser settings of the gpm command flags of the same
+// names, so that the command and the bindings parse files alike.
+type Options struct {
+	Encoding        string
+	Duplicates      string
+	JavaSeparators  bool
+	JavaWhitespace  bool
+	UnicodeEscapes  bool
+	CommentPrefixes []string
+	CommentBlocks   bool
+	QuotedValues    bool
+}
+
+// DefaultOptions returns the settings of the gpm command without flags.
+func DefaultOptions() Options {
+	return Options{
+		Encoding:   gpm.ENCODING_AUTO,
+		Duplicates: gpm.DUPLICATES_KEEP_ALL,
+	}
+}
+
+// ParserOptions returns the parser options of o.
+func (o Options) ParserOptions() []gpm.ParserOption {
+	opts := []gpm.ParserOption{gpm.WithDecoding(o.Encoding), gpm.WithDuplicates(o.Duplicates)}
+	if o.JavaSeparators {
+		opts = append(opts, gpm.WithJavaSeparators())
+	}
+	if o.JavaWhitespace {
+		opts = append(opts, gpm.WithJavaWhitespace())
+	}
+	if o.UnicodeEscapes {
+		opts = append(opts, gpm.WithUnicodeDecoding())
+	}
+	if len(o.CommentPrefixes) > 0 {
+		opts = append(opts, gpm.WithCommentPrefixes(o.CommentPrefixes...))
+	}
+	if o.CommentBlocks {
+		opts = append(opts, gpm.WithCommentBlocks())
+	}
+	if o.QuotedValues {
+		opts = append(opts, gpm.WithQuotedValues())
+	}
+	return opts
+}
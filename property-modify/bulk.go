@@ -0,0 +1,72 @@
+package gpm
+
+import (
+	"errors"
+	"sort"
+)
+
+// CHANGE_NONE is the result of a key SetProperties or RemoveProperties
+// left as it was: already set to the value, or not set to be removed.
+const CHANGE_NONE = "none"
+
+// SetProperties sets every key of props like SetProperty, and returns
+// what happened to each key: CHANGE_ADDED, CHANGE_CHANGED or CHANGE_NONE.
+// New keys are appended in sorted order, a map having none. The batch is
+// checked as a whole first: if the key validator rejects a new key or a
+// value violates the constraints, see SetConstraints, nothing is changed
+// and the error lists every such key.
+func (m *Modifier) SetProperties(props map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if _, ok := m.index[key]; !ok {
+			if err := m.validateKey(key); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if err := m.constraints.Check(key, props[key]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	results := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value := props[key]
+		old, ok := m.Get(key)
+		switch {
+		case !ok:
+			results[key] = CHANGE_ADDED
+		case old != value:
+			results[key] = CHANGE_CHANGED
+		default:
+			results[key] = CHANGE_NONE
+			continue
+		}
+		m.setProperty(key, value, nil)
+	}
+	return results, nil
+}
+
+// RemoveProperties removes every key of keys like RemoveProperty, and
+// returns what happened to each key: CHANGE_REMOVED or CHANGE_NONE if it
+// wasn't set.
+func (m *Modifier) RemoveProperties(keys []string) map[string]string {
+	results := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if m.RemoveProperty(key) {
+			results[key] = CHANGE_REMOVED
+		} else if _, ok := results[key]; !ok {
+			results[key] = CHANGE_NONE
+		}
+	}
+	return results
+}
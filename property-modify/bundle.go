@@ -0,0 +1,195 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const PROPERTIES_EXT = ".properties"
+
+// BundleLocale is one translated file of a resource bundle, e.g.
+// messages_de.properties.
+type BundleLocale struct {
+	Locale   string
+	Path     string
+	Modifier *Modifier
+}
+
+// Bundle is a Java resource bundle: a base file like messages.properties
+// and its translations messages_<locale>.properties in the same
+// directory.
+type Bundle struct {
+	BasePath string
+	Base     *Modifier
+	// Locales are sorted by locale name.
+	Locales []*BundleLocale
+}
+
+// BundleDiff lists the keys of a locale that differ from the base file.
+type BundleDiff struct {
+	Locale  string
+	Missing []string // in the base file but not the locale, in base order
+	Extra   []string // in the locale but not the base file, in locale order
+}
+
+// LoadBundle parses the base file at path and every translation next to
+// it.
+func LoadBundle(path string) (*Bundle, error) {
+	base, err := loadModifier(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{
+		BasePath: path,
+		Base:     base,
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), PROPERTIES_EXT)
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), name+"_*"+PROPERTIES_EXT))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	for _, match := range matches {
+		locale := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), name+"_"), PROPERTIES_EXT)
+		m, err := loadModifier(match)
+		if err != nil {
+			return nil, err
+		}
+		b.Locales = append(b.Locales, &BundleLocale{
+			Locale:   locale,
+			Path:     match,
+			Modifier: m,
+		})
+	}
+	return b, nil
+}
+
+func loadModifier(path string) (*Modifier, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	doc, err := Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Modifier, nil
+}
+
+// Report lists the missing and extra keys of every locale.
+func (b *Bundle) Report() []BundleDiff {
+	diffs := make([]BundleDiff, 0, len(b.Locales))
+	for _, l := range b.Locales {
+		diff := BundleDiff{Locale: l.Locale}
+		for _, p := range b.Base.lines() {
+			if p.key == "" {
+				continue
+			}
+			if _, ok := l.Modifier.index[p.key]; !ok {
+				diff.Missing = append(diff.Missing, p.key)
+			}
+		}
+		for _, p := range l.Modifier.lines() {
+			if p.key == "" {
+				continue
+			}
+			if _, ok := b.Base.index[p.key]; !ok {
+				diff.Extra = append(diff.Extra, p.key)
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// Sync copies the keys missing from each locale from the base file,
+// marking them with the comment marker so translators can find them. It
+// returns the copied keys per locale.
+func (b *Bundle) Sync(marker string) map[string][]string {
+	copied := make(map[string][]string)
+	for _, diff := range b.Report() {
+		l := b.locale(diff.Locale)
+		for _, key := range diff.Missing {
+			// the base file has the key already, whatever the validator
+			l.Modifier.setProperty(key, b.Base.index[key].value, &marker)
+			copied[diff.Locale] = append(copied[diff.Locale], key)
+		}
+	}
+	return copied
+}
+
+// Order sorts the keys of every locale in the order of the base file.
+// Comment lines directly above a key move with it, keys that are not in
+// the base file go last.
+func (b *Bundle) Order() {
+	order := make(map[string]int)
+	for _, p := range b.Base.lines() {
+		if p.key != "" {
+			order[p.key] = len(order)
+		}
+	}
+	for _, l := range b.Locales {
+		l.Modifier.orderBy(func(key string) (int, bool) {
+			i, ok := order[key]
+			return i, ok
+		})
+	}
+}
+
+// Path returns the file of locale, or "" if the bundle has no such
+// locale.
+func (b *Bundle) Path(locale string) string {
+	if l := b.locale(locale); l != nil {
+		return l.Path
+	}
+	return ""
+}
+
+func (b *Bundle) locale(name string) *BundleLocale {
+	for _, l := range b.Locales {
+		if l.Locale == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// orderBy sorts the keys by rank, keeping the comment and blank lines
+// above a key together with it. Keys without a rank keep their relative
+// order after the ranked ones.
+func (m *Modifier) orderBy(rank func(key string) (int, bool)) {
+	type block struct {
+		props  []Property
+		rank   int
+		ranked bool
+	}
+	var blocks []block
+	var pending []Property
+	lines := m.lines()
+	for _, p := range lines {
+		pending = append(pending, p)
+		if p.key == "" {
+			continue
+		}
+		r, ok := rank(p.key)
+		blocks = append(blocks, block{props: pending, rank: r, ranked: ok})
+		pending = nil
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].ranked != blocks[j].ranked {
+			return blocks[i].ranked
+		}
+		return blocks[i].ranked && blocks[i].rank < blocks[j].rank
+	})
+
+	props := make([]Property, 0, len(lines))
+	for _, b := range blocks {
+		props = append(props, b.props...)
+	}
+	m.setLines(append(props, pending...))
+}
@@ -0,0 +1,79 @@
+//go:build cgo
+
+// Command capi builds the gpm core as a C shared library for Gradle
+// plugins and other native callers:
+//
+//	go build -buildmode=c-shared -o libgpm.so ./capi
+//
+// Every returned string is allocated with malloc and must be released
+// with GpmFree. On failure the result is NULL and *err holds the message,
+// *err is set to NULL otherwise.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"gpm/bridge"
+	"unsafe"
+)
+
+func result(s string, err error, errOut **C.char) *C.char {
+	clearError(errOut)
+	if err != nil {
+		if errOut != nil {
+			*errOut = C.CString(err.Error())
+		}
+		return nil
+	}
+	return C.CString(s)
+}
+
+// clearError sets *errOut to NULL, so that callers can tell a NULL
+// result without an error.
+func clearError(errOut **C.char) {
+	if errOut != nil {
+		*errOut = nil
+	}
+}
+
+// GpmApply applies a JSON array of operations to a property file and
+// returns the saved file.
+//
+//export GpmApply
+func GpmApply(text, ops *C.char, errOut **C.char) *C.char {
+	out, err := bridge.Apply(C.GoString(text), C.GoString(ops))
+	return result(out, err, errOut)
+}
+
+// GpmGet returns the value of key, or NULL without an error if the key is
+// missing.
+//
+//export GpmGet
+func GpmGet(text, key *C.char, errOut **C.char) *C.char {
+	value, ok, err := bridge.Get(C.GoString(text), C.GoString(key))
+	if err == nil && !ok {
+		clearError(errOut)
+		return nil
+	}
+	return result(value, err, errOut)
+}
+
+// GpmAST returns the -dump-ast json model of a property file.
+//
+//export GpmAST
+func GpmAST(text *C.char, errOut **C.char) *C.char {
+	out, err := bridge.AST(C.GoString(text))
+	return result(out, err, errOut)
+}
+
+// GpmFree releases a string returned by the library.
+//
+//export GpmFree
+func GpmFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+)
+
+func runAnonymize(args []string) int {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file to copy")
+	output := fs.String("output", "", "Anonymized copy to write")
+	seed := fs.Int64("seed", 1, "Seed of the placeholders, the same seed gives the same copy")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify anonymize [options]")
+		fmt.Println("Copy a property file with every word of its keys, values and comments replaced by a placeholder")
+		fmt.Println("of the same length, keeping the structure, to share a file reproducing a parser bug.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *output == "" || *output == *input {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := readInput(*input)
+	if err != nil {
+		fmt.Println("Error reading input file:", err)
+		return 2
+	}
+	anonymized := gpm.Anonymize(data, *seed)
+	if err := writeOutput(*output, func(w io.Writer) error {
+		_, err := w.Write(anonymized)
+		return err
+	}); err != nil {
+		return 1
+	}
+	fmt.Printf("Anonymized %s to %s\n", *input, *output)
+	return 0
+}
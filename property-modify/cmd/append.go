@@ -0,0 +1,92 @@
+package main
+
+import (
+	"gpm"
+	"io"
+	"os"
+	"strings"
+)
+
+// appendProperties appends every 'key=value#comment' argument as is, a
+// line each, to the end of input, writing to output, without parsing the
+// file. When input and output are the same file, the file is only opened
+// for appending; otherwise it is copied as a stream. A key already set
+// gets a second line, which Java lets win, so an appended key overrides
+// an earlier one.
+func appendProperties(input, output string, args []string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	newline := lastLineEnding(in)
+
+	var sb strings.Builder
+	for _, arg := range args {
+		if _, _, _, err := parseSetArg(arg); err != nil {
+			return err
+		}
+		sb.WriteString(arg + newline)
+	}
+
+	if output == input {
+		file, err := os.OpenFile(input, os.O_RDWR|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if missingNewline(file) {
+			if _, err := io.WriteString(file, newline); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(file, sb.String()); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+
+	return writeOutput(output, func(w io.Writer) error {
+		if _, err := io.Copy(w, in); err != nil {
+			return err
+		}
+		if missingNewline(in) {
+			if _, err := io.WriteString(w, newline); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, sb.String())
+		return err
+	})
+}
+
+// lastLineEnding returns the line ending of the last line of file, "\r\n"
+// or "\n", so that appended lines match it.
+func lastLineEnding(file *os.File) string {
+	info, err := file.Stat()
+	if err != nil || info.Size() < 2 {
+		return gpm.LINE_ENDING_LF
+	}
+	last := make([]byte, 2)
+	if _, err := file.ReadAt(last, info.Size()-2); err != nil {
+		return gpm.LINE_ENDING_LF
+	}
+	if string(last) == gpm.LINE_ENDING_CRLF {
+		return gpm.LINE_ENDING_CRLF
+	}
+	return gpm.LINE_ENDING_LF
+}
+
+// missingNewline reports whether the non-empty file doesn't end with a
+// newline.
+func missingNewline(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false
+	}
+	return last[0] != '\n'
+}
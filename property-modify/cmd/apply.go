@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"os"
+	"sort"
+)
+
+// State is the desired state of a property file read by the apply
+// command:
+//
+//	present:
+//	  org.gradle.caching: "true"
+//	absent: ["android.enableJetifier", "debug.*"]
+//	ignore: ["local.*"]
+//	exclusive: true
+//
+// Keys of present must exist with their value, keys matching an absent
+// pattern must not exist. With exclusive, every other key is removed too,
+// except the ones matching an ignore pattern, which are always left alone.
+type State struct {
+	Present   map[string]string `yaml:"present" json:"present"`
+	Absent    []string          `yaml:"absent" json:"absent"`
+	Ignore    []string          `yaml:"ignore" json:"ignore"`
+	Exclusive bool              `yaml:"exclusive" json:"exclusive"`
+
+	absent, ignore gpm.KeyMatchers
+}
+
+func loadState(file string) (*State, error) {
+	var state State
+	if err := loadData(file, &state); err != nil {
+		return nil, err
+	}
+	validate := gpm.ValidateKey
+	if *javaSeparators {
+		validate = gpm.ValidateJavaKey
+	}
+	for key := range state.Present {
+		if err := validate(key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", key, err)
+		}
+	}
+	var err error
+	if state.absent, err = gpm.CompileKeyGlobs(state.Absent); err != nil {
+		return nil, err
+	}
+	if state.ignore, err = gpm.CompileKeyGlobs(state.Ignore); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// operations returns the operations converging a file with keys to the
+// state. Applying them to a converged file changes nothing.
+func (s *State) operations(keys []string) []Operation {
+	var operations []Operation
+	for _, key := range keys {
+		if _, ok := s.Present[key]; ok || s.ignore.Match(key) {
+			continue
+		}
+		if s.Exclusive || s.absent.Match(key) {
+			operations = append(operations, Operation{Type: OP_TYPE_RM, Key: key})
+		}
+	}
+
+	present := make([]string, 0, len(s.Present))
+	for k := range s.Present {
+		present = append(present, k)
+	}
+	sort.Strings(present)
+	for _, k := range present {
+		operations = append(operations, Operation{Type: OP_TYPE_SET, Key: k, Value: s.Present[k]})
+	}
+	return operations
+}
+
+// converge applies the operations of the state to modifier and returns
+// the ones that changed something, the drift.
+func (s *State) converge(modifier *gpm.Modifier, keys []string) []Explanation {
+	var drift []Explanation
+	for _, op := range s.operations(keys) {
+		_, existed := modifier.Get(op.Key)
+		before := modifier.Fingerprint()
+		switch op.Type {
+		case OP_TYPE_SET:
+			// loadState validated the keys
+			modifier.SetProperty(op.Key, op.Value, nil)
+		case OP_TYPE_RM:
+			modifier.RemoveProperty(op.Key)
+		}
+		if e := explain(op, existed, modifier.Fingerprint() != before); e.Outcome != OUTCOME_NO_OP {
+			drift = append(drift, e)
+		}
+	}
+	return drift
+}
+
+// propertyKeys returns the keys of props in file order.
+func propertyKeys(props []gpm.Property) []string {
+	var keys []string
+	for _, p := range props {
+		if p.Key() != "" {
+			keys = append(keys, p.Key())
+		}
+	}
+	return keys
+}
+
+func runApply(args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file to converge")
+	stateFile := fs.String("state", "", "YAML or JSON desired state: present keys with their values, absent and ignored key patterns")
+	check := fs.Bool("check", false, "Only report the drift and exit 1 if there is any, without writing the file")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify apply [options]")
+		fmt.Println("Converge a property file to a desired state and report the drift that was fixed.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *stateFile == "" {
+		fs.Usage()
+		return 2
+	}
+
+	state, err := loadState(*stateFile)
+	if err != nil {
+		fmt.Println("Error reading state:", err)
+		return 2
+	}
+	doc, err := parseInput(*input)
+	if err != nil {
+		return 2
+	}
+	modifier := doc.Modifier
+
+	drift := state.converge(modifier, propertyKeys(doc.Props()))
+	if len(drift) == 0 {
+		fmt.Println(*input, "is up to date")
+		return 0
+	}
+	writeExplanations(os.Stdout, drift)
+	if *check {
+		return 1
+	}
+	if err := saveOutput(*input, modifier); err != nil {
+		return 1
+	}
+	return 0
+}
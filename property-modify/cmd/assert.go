@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"strings"
+)
+
+func runAssert(args []string) int {
+	fs := flag.NewFlagSet("assert", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file to check")
+	golden := fs.String("golden", "", "Expected property file")
+	ignoreKeys := fs.String("ignore-keys", "", "Comma separated keys, path.Match patterns or re: regular expressions to ignore, e.g. 'build.timestamp,ci.*'")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify assert [options]")
+		fmt.Println("Exit 1 unless the keys and values of the input match the golden file. Comments and layout are ignored.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *golden == "" {
+		fs.Usage()
+		return 2
+	}
+
+	patterns, err := parseKeyPatterns(*ignoreKeys)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 2
+	}
+
+	expected, err := parseInput(*golden)
+	if err != nil {
+		return 2
+	}
+	actual, err := parseInput(*input)
+	if err != nil {
+		return 2
+	}
+
+	mismatch := false
+	for _, c := range gpm.DiffProperties(expected.Props(), actual.Props()) {
+		if patterns.Match(c.Key) {
+			continue
+		}
+		mismatch = true
+		fmt.Println(c)
+	}
+	if mismatch {
+		fmt.Printf("%s does not match %s\n", *input, *golden)
+		return 1
+	}
+	return 0
+}
+
+// parseKeyPatterns splits a comma separated list of keys, path.Match
+// patterns and re: regular expressions.
+func parseKeyPatterns(list string) (gpm.KeyMatchers, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return gpm.CompileKeyGlobs(patterns)
+}
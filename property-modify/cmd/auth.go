@@ -0,0 +1,119 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"gpm"
+	"net/http"
+	"strings"
+)
+
+const (
+	ROLE_READ  = "read"
+	ROLE_WRITE = "write"
+	ROLE_ADMIN = "admin"
+)
+
+// AuthConfig lists the tokens accepted by the server:
+//
+//	tokens:
+//	  - name: ci
+//	    token: s3cret
+//	    role: write
+//	    keys: ["app.version", "build.*"]
+//	  - name: dashboard
+//	    token: r3ad
+//	    role: read
+type AuthConfig struct {
+	Tokens []Token `yaml:"tokens" json:"tokens"`
+}
+
+// Token is a client of the server. Role "read" may only read, "write" may
+// also set and remove the keys matching one of Keys (path.Match
+// patterns or re: regular expressions), "admin" may do everything.
+type Token struct {
+	Name  string   `yaml:"name" json:"name"`
+	Token string   `yaml:"token" json:"token"`
+	Role  string   `yaml:"role" json:"role"`
+	Keys  []string `yaml:"keys" json:"keys"`
+
+	keys gpm.KeyMatchers
+}
+
+func loadAuthConfig(file string) (*AuthConfig, error) {
+	var config AuthConfig
+	if err := loadData(file, &config); err != nil {
+		return nil, err
+	}
+	for i, t := range config.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("token %d (%s) is empty", i, t.Name)
+		}
+		switch t.Role {
+		case ROLE_READ, ROLE_WRITE, ROLE_ADMIN:
+		default:
+			return nil, fmt.Errorf("token %d (%s) has unknown role %q", i, t.Name, t.Role)
+		}
+		keys, err := gpm.CompileKeyGlobs(t.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("token %d (%s): %w", i, t.Name, err)
+		}
+		config.Tokens[i].keys = keys
+	}
+	return &config, nil
+}
+
+// find returns the token matching the bearer token of a request.
+func (c *AuthConfig) find(bearer string) *Token {
+	for i := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(c.Tokens[i].Token), []byte(bearer)) == 1 {
+			return &c.Tokens[i]
+		}
+	}
+	return nil
+}
+
+// CanWrite reports whether the token may change key.
+func (t *Token) CanWrite(key string) bool {
+	switch t.Role {
+	case ROLE_ADMIN:
+		return true
+	case ROLE_WRITE:
+		return t.keys.Match(key)
+	}
+	return false
+}
+
+// authenticate rejects requests without a known bearer token and
+// remembers the token of the others in their requestInfo. It lets
+// everything through when no tokens are configured.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		token := s.auth.find(strings.TrimSpace(bearer))
+		if !ok || token == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gpm"`)
+			http.Error(w, "missing or unknown token", http.StatusUnauthorized)
+			return
+		}
+		if info := infoOf(r); info != nil {
+			info.token = token
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeWrite answers 403 if the client may not change key.
+func (s *Server) authorizeWrite(w http.ResponseWriter, r *http.Request, key string) bool {
+	info := infoOf(r)
+	if info == nil || info.token == nil || info.token.CanWrite(key) {
+		return true
+	}
+	http.Error(w, fmt.Sprintf("%s may not change %s", info.token.Name, key), http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+	"math/rand"
+	"runtime"
+	"time"
+)
+
+// benchResult is what one benchmarked step cost per run.
+type benchResult struct {
+	name   string
+	time   time.Duration
+	allocs uint64
+	bytes  uint64
+}
+
+// measure runs fn n times and returns its average cost.
+func measure(name string, n int, fn func() error) (benchResult, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := fn(); err != nil {
+			return benchResult{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return benchResult{
+		name:   name,
+		time:   elapsed / time.Duration(n),
+		allocs: (after.Mallocs - before.Mallocs) / uint64(n),
+		bytes:  (after.TotalAlloc - before.TotalAlloc) / uint64(n),
+	}, nil
+}
+
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	runs := fs.Int("n", 20, "Runs of every step, the report shows their average")
+	sets := fs.Int("sets", 100, "Values set by the modify step, on keys picked at random")
+	seed := fs.Int64("seed", 1, "Seed of the random keys and values")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify bench [options] file")
+		fmt.Println("Time the parse, modify and save steps on a property file and report their allocations, to")
+		fmt.Println("compare the in-memory parse with the streaming one of ParseFunc. Nothing is written.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *runs < 1 || *sets < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	path := fs.Arg(0)
+	data, err := readInput(path)
+	if err != nil {
+		fmt.Println("Error reading input file:", err)
+		return 1
+	}
+	doc, err := gpm.ParseBytes(data)
+	if err != nil {
+		fmt.Println("Error parsing input file:", err)
+		return 1
+	}
+	lines := len(doc.Props())
+	keys := doc.Keys()
+	count := len(keys)
+	if len(keys) == 0 {
+		keys = []string{"bench.key"}
+	}
+	rnd := rand.New(rand.NewSource(*seed))
+	picked := make([]string, *sets)
+	for i := range picked {
+		picked[i] = keys[rnd.Intn(len(keys))]
+	}
+
+	var results []benchResult
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"parse (in memory)", func() error {
+			return gpm.NewParser().Parse(bytes.NewReader(data))
+		}},
+		{"parse (streaming)", func() error {
+			return gpm.NewParser().ParseFunc(bytes.NewReader(data), func(gpm.Property) error {
+				return nil
+			})
+		}},
+		{"parse (preserving)", func() error {
+			return gpm.NewParser().ParsePreserving(bytes.NewReader(data))
+		}},
+	}
+	for _, step := range steps {
+		result, err := measure(step.name, *runs, step.fn)
+		if err != nil {
+			fmt.Println("Error parsing input file:", err)
+			return 1
+		}
+		results = append(results, result)
+	}
+
+	// modify and save run on the same document, the sets only change
+	// existing keys so that the file keeps its size
+	result, _ := measure(fmt.Sprintf("modify (%d sets)", *sets), *runs, func() error {
+		for i, key := range picked {
+			doc.Set(key, fmt.Sprintf("bench-%d", rnd.Intn(i+1)))
+		}
+		return nil
+	})
+	results = append(results, result)
+	result, err = measure("save", *runs, func() error {
+		return doc.Save(io.Discard)
+	})
+	if err != nil {
+		fmt.Println("Error saving output:", err)
+		return 1
+	}
+	results = append(results, result)
+
+	fmt.Printf("%s: %d bytes, %d lines, %d keys, %d runs per step\n", path, len(data), lines, count, *runs)
+	fmt.Printf("%-20s %12s %12s %14s\n", "step", "time/run", "allocs/run", "bytes/run")
+	for _, r := range results {
+		fmt.Printf("%-20s %12s %12d %14d\n", r.name, r.time.Round(time.Microsecond), r.allocs, r.bytes)
+	}
+	if len(data) > 0 {
+		memory, streaming := results[0], results[1]
+		fmt.Printf("The in-memory parse allocates %.1f bytes per byte of the file, the streaming one %.1f.\n",
+			float64(memory.bytes)/float64(len(data)), float64(streaming.bytes)/float64(len(data)))
+	}
+	return 0
+}
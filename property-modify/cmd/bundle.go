@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"strings"
+)
+
+func runBundle(args []string) int {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	base := fs.String("base", "messages.properties", "Base file of the resource bundle, translations are found next to it as <name>_<locale>.properties")
+	sync := fs.Bool("sync", false, "Copy keys missing from a locale from the base file, marked with -marker")
+	marker := fs.String("marker", "TODO: translate", "Comment added to keys copied by -sync")
+	order := fs.Bool("order", false, "Sort the keys of every locale in the order of the base file")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify bundle [options]")
+		fmt.Println("Without -sync or -order, report missing and extra keys per locale and translations")
+		fmt.Println("whose MessageFormat or printf placeholders differ from the base file, and exit 1 if there are any.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	bundle, err := gpm.LoadBundle(*base)
+	if err != nil {
+		fmt.Println("Error loading bundle:", err)
+		return 2
+	}
+
+	if !*sync && !*order {
+		incomplete := false
+		for _, diff := range bundle.Report() {
+			if len(diff.Missing) == 0 && len(diff.Extra) == 0 {
+				continue
+			}
+			incomplete = true
+			fmt.Printf("%s:\n", diff.Locale)
+			if len(diff.Missing) > 0 {
+				fmt.Printf("  missing: %s\n", strings.Join(diff.Missing, ", "))
+			}
+			if len(diff.Extra) > 0 {
+				fmt.Printf("  extra: %s\n", strings.Join(diff.Extra, ", "))
+			}
+		}
+		for _, m := range bundle.CheckPlaceholders() {
+			incomplete = true
+			fmt.Printf("%s:%d: %s: placeholders [%s], expected [%s]\n",
+				bundle.Path(m.Locale), m.Line, m.Key, strings.Join(m.Found, " "), strings.Join(m.Expected, " "))
+		}
+		if incomplete {
+			return 1
+		}
+		return 0
+	}
+
+	if *sync {
+		copied := bundle.Sync(*marker)
+		for _, l := range bundle.Locales {
+			if keys := copied[l.Locale]; len(keys) > 0 {
+				fmt.Printf("%s: copied %s\n", l.Locale, strings.Join(keys, ", "))
+			}
+		}
+	}
+	if *order {
+		bundle.Order()
+	}
+	for _, l := range bundle.Locales {
+		if err := saveOutput(l.Path, l.Modifier); err != nil {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"gpm/wire"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Capabilities of the binary that depend on its build tags, added by the
+// init functions of the files left out of gpm_core builds.
+var (
+	networkResolvers []string
+	serverFeatures   []string
+)
+
+func init() {
+	// registered here as it lists the commands
+	registerCommand(Command{"capabilities", "List the features of this binary, which depend on its build tags", runCapabilities})
+}
+
+// capabilities returns what the running binary supports.
+func capabilities() wire.Capabilities {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	resolvers := append([]string{
+		"defaults-file",
+		"storage-file",
+		"merge-file",
+		"registry-file",
+		"rotate-plugin",
+		"modified-" + MODIFIED_FILE,
+		"modified-" + MODIFIED_ANNOTATION,
+		"modified-" + MODIFIED_GIT,
+		"git-history",
+	}, networkResolvers...)
+	server := slices.Clone(serverFeatures)
+	slices.Sort(resolvers)
+	slices.Sort(server)
+	if server == nil {
+		server = []string{}
+	}
+	return wire.Capabilities{
+		Version:   VERSION,
+		Commands:  names,
+		Dialects:  []string{"java-separators", "comment-prefixes", "unicode-escapes", "quoted-values", "java-whitespace"},
+		Encodings: []string{gpm.ENCODING_UTF8, gpm.ENCODING_LATIN1, gpm.ENCODING_AUTO},
+		Converters: []string{
+			"ascii-" + gpm.ASCII_ESCAPE,
+			"ascii-" + gpm.ASCII_TRANSLITERATE,
+			"ast-json",
+			"diagnostics-json",
+			"diagnostics-sarif",
+			"gen-go",
+			"redact",
+			"render",
+		},
+		Resolvers: resolvers,
+		Server:    server,
+	}
+}
+
+func runCapabilities(args []string) int {
+	fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print them as JSON")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify capabilities [options]")
+		fmt.Println("List the commands, dialects, encodings, converters, resolvers and server features of this")
+		fmt.Println("binary, which depend on its version and build tags.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	c := capabilities()
+	if *asJSON {
+		if err := wire.Write(os.Stdout, wire.NewCapabilities(c)); err != nil {
+			fmt.Println("Error listing capabilities:", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Println("version:", c.Version)
+	for _, list := range []struct {
+		name  string
+		items []string
+	}{
+		{"commands", c.Commands},
+		{"dialects", c.Dialects},
+		{"encodings", c.Encodings},
+		{"converters", c.Converters},
+		{"resolvers", c.Resolvers},
+		{"server", c.Server},
+	} {
+		fmt.Printf("%s: %s\n", list.name, strings.Join(list.items, ", "))
+	}
+	return 0
+}
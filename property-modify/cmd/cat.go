@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CAT_MARKER_PREFIX and CAT_MARKER_SUFFIX surround the path of the file
+// the lines after them come from in the output of cat.
+const (
+	CAT_MARKER_PREFIX = "# --- source: "
+	CAT_MARKER_SUFFIX = " ---"
+)
+
+// catSection is a file of a concatenated stream.
+type catSection struct {
+	path string
+	text bytes.Buffer
+}
+
+// keyPrefix returns the prefix cat -prefix-keys adds to the keys of the
+// file at path: its name without extension and a '.'.
+func keyPrefix(path string) string {
+	name := filepath.Base(filepath.ToSlash(path))
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "."
+}
+
+// renameKeys renames every key of doc with rename.
+func renameKeys(doc *gpm.Document, rename func(key string) string) error {
+	seen := make(map[string]bool)
+	for _, key := range doc.Keys() {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := doc.RenameKey(key, rename(key), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runCat(args []string) int {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	output := fs.String("output", "", "File to write, default is stdout")
+	prefixKeys := fs.Bool("prefix-keys", false, "Prefix the keys of every file with its name without extension and a '.', e.g. 'app.' for app.properties")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify cat [options] file...")
+		fmt.Println("Concatenate property files into one, each after a '" + CAT_MARKER_PREFIX + "path" + CAT_MARKER_SUFFIX + "' line, e.g. to ship layered config as a single artifact. split-by-marker splits it back.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var out bytes.Buffer
+	for _, path := range fs.Args() {
+		doc, err := loadPreserving(path)
+		if err != nil {
+			fmt.Println("Error reading input file:", err)
+			return 2
+		}
+		if *prefixKeys {
+			prefix := keyPrefix(path)
+			if err := renameKeys(doc, func(key string) string { return prefix + key }); err != nil {
+				fmt.Printf("Error prefixing the keys of %s: %v\n", path, err)
+				return 1
+			}
+		}
+		// the stream is UTF-8 whatever the inputs are
+		doc.SetEncoding(gpm.ENCODING_UTF8)
+		doc.SetBOM(false)
+		fmt.Fprintf(&out, "%s%s%s\n", CAT_MARKER_PREFIX, filepath.ToSlash(path), CAT_MARKER_SUFFIX)
+		if err := doc.Save(&out); err != nil {
+			fmt.Println("Error concatenating:", err)
+			return 1
+		}
+	}
+
+	if *output == "" {
+		if _, err := os.Stdout.Write(out.Bytes()); err != nil {
+			fmt.Println("Error writing output:", err)
+			return 1
+		}
+		return 0
+	}
+	if err := writeOutput(*output, func(w io.Writer) error {
+		_, err := w.Write(out.Bytes())
+		return err
+	}); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// loadPreserving reads the property file at path in its encoding, keeping
+// its layout.
+func loadPreserving(path string) (*gpm.Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return gpm.ParsePreserving(file, gpm.WithDecoding(gpm.ENCODING_AUTO))
+}
+
+// splitSections splits a stream written by cat at its markers.
+func splitSections(data []byte) ([]*catSection, error) {
+	var sections []*catSection
+	for i, line := range strings.SplitAfter(string(data), "\n") {
+		content := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(content, CAT_MARKER_PREFIX) && strings.HasSuffix(content, CAT_MARKER_SUFFIX) {
+			path := strings.TrimSuffix(strings.TrimPrefix(content, CAT_MARKER_PREFIX), CAT_MARKER_SUFFIX)
+			sections = append(sections, &catSection{path: path})
+			continue
+		}
+		if len(sections) == 0 {
+			if strings.TrimSpace(content) != "" {
+				return nil, fmt.Errorf("line %d: content before the first source marker", i+1)
+			}
+			continue
+		}
+		sections[len(sections)-1].text.WriteString(line)
+	}
+	return sections, nil
+}
+
+func runSplitByMarker(args []string) int {
+	fs := flag.NewFlagSet("split-by-marker", flag.ExitOnError)
+	input := fs.String("input", "-", "Stream written by cat, - for stdin")
+	dir := fs.String("dir", ".", "Directory the paths of the markers are relative to")
+	stripPrefix := fs.Bool("strip-prefix", false, "Remove the prefix added by cat -prefix-keys from the keys")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify split-by-marker [options]")
+		fmt.Println("Write back the files concatenated by cat, each to the path of its source marker.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var data []byte
+	var err error
+	if *input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		fmt.Println("Error reading input file:", err)
+		return 2
+	}
+	sections, err := splitSections(data)
+	if err != nil {
+		fmt.Println("Error parsing input file:", err)
+		return 2
+	}
+
+	for _, section := range sections {
+		if !filepath.IsLocal(section.path) {
+			fmt.Printf("Error: %s is outside of -dir, not writing it\n", section.path)
+			return 1
+		}
+		save := func(w io.Writer) error {
+			_, err := w.Write(section.text.Bytes())
+			return err
+		}
+		if *stripPrefix {
+			doc, err := gpm.ParsePreserving(&section.text)
+			if err != nil {
+				fmt.Println("Error parsing input file:", err)
+				return 2
+			}
+			prefix := keyPrefix(section.path)
+			if err := renameKeys(doc, func(key string) string { return strings.TrimPrefix(key, prefix) }); err != nil {
+				fmt.Printf("Error removing the key prefix of %s: %v\n", section.path, err)
+				return 1
+			}
+			save = func(w io.Writer) error {
+				return doc.Save(w)
+			}
+		}
+
+		output := filepath.Join(*dir, filepath.FromSlash(section.path))
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			fmt.Println("Error creating output directory:", err)
+			return 1
+		}
+		if err := writeOutput(output, save); err != nil {
+			return 1
+		}
+		fmt.Println("Wrote", output)
+	}
+	return 0
+}
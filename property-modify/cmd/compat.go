@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"gpm"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const BOM = "\uFEFF"
+
+// compatFinding is a line that didn't survive a round trip.
+type compatFinding struct {
+	Line      int
+	Construct string
+	Before    string
+	After     string
+}
+
+func runCompat(args []string) int {
+	fset := flag.NewFlagSet("compat", flag.ExitOnError)
+	pattern := fset.String("pattern", "*"+gpm.PROPERTIES_EXT, "File name pattern of the files to check")
+	verbose := fset.Bool("v", false, "Print every changed line, not only the summary")
+	fset.Usage = func() {
+		fmt.Println("Usage: property-modify compat [options] dir...")
+		fmt.Println("Parse and save every property file under the directories without writing anything, and report")
+		fmt.Println("the constructs the round trip changed. Exit 1 if any file doesn't round-trip unchanged.")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+	if fset.NArg() == 0 {
+		fset.Usage()
+		return 2
+	}
+
+	var files, changed, failed int
+	constructs := make(map[string]int)
+	for _, dir := range fset.Args() {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if ok, _ := filepath.Match(*pattern, d.Name()); !ok {
+				return nil
+			}
+			files++
+
+			findings, err := roundTrip(path)
+			if err != nil {
+				failed++
+				fmt.Printf("%s: FAILED: %v\n", path, err)
+				return nil
+			}
+			if len(findings) == 0 {
+				return nil
+			}
+			changed++
+			perFile := make(map[string]int)
+			for _, f := range findings {
+				constructs[f.Construct]++
+				perFile[f.Construct]++
+				if *verbose {
+					fmt.Printf("%s:%d: %s\n  - %q\n  + %q\n", path, f.Line, f.Construct, f.Before, f.After)
+				}
+			}
+			if !*verbose {
+				fmt.Printf("%s: %d changed lines (%s)\n", path, len(findings), formatCounts(perFile))
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Println("Error walking directory:", err)
+			return 2
+		}
+	}
+
+	fmt.Printf("%d files, %d round-trip unchanged, %d changed, %d failed\n", files, files-changed-failed, changed, failed)
+	if len(constructs) > 0 {
+		fmt.Println("changed constructs:", formatCounts(constructs))
+	}
+	if changed > 0 || failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// roundTrip parses and saves the file at path and returns the lines that
+// changed.
+func roundTrip(path string) ([]compatFinding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parser := gpm.NewParser()
+	if err := parser.Parse(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	modifier := gpm.NewModifier(parser.GetProps())
+	var out bytes.Buffer
+	if err := modifier.Save(&out); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(data, out.Bytes()) {
+		return nil, nil
+	}
+
+	before := strings.Split(string(data), "\n")
+	after := strings.Split(out.String(), "\n")
+	var findings []compatFinding
+	for i := 0; i < len(before) || i < len(after); i++ {
+		var b, a string
+		if i < len(before) {
+			b = before[i]
+		}
+		if i < len(after) {
+			a = after[i]
+		}
+		if a == b {
+			continue
+		}
+		findings = append(findings, compatFinding{
+			Line:      i + 1,
+			Construct: classify(b, i == 0, i == len(before)-1),
+			Before:    b,
+			After:     a,
+		})
+	}
+	return findings, nil
+}
+
+// classify guesses the construct of an original line that a round trip
+// changed.
+func classify(line string, first, last bool) string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case last && line == "":
+		return "missing trailing newline"
+	case first && strings.HasPrefix(line, BOM):
+		return "byte order mark"
+	case strings.HasSuffix(line, "\r"):
+		return "CRLF line ending"
+	case strings.HasSuffix(trimmed, "\\") && (len(trimmed)-len(strings.TrimRight(trimmed, "\\")))%2 == 1:
+		return "line continuation"
+	case strings.HasPrefix(trimmed, "!"):
+		return "'!' comment"
+	case strings.Contains(line, `\#`) || strings.Contains(line, `\!`):
+		return "escaped comment character"
+	case strings.Contains(line, `\u`):
+		return "unicode escape"
+	case strings.HasPrefix(trimmed, "#"):
+		return "comment formatting"
+	case strings.ContainsRune(trimmed, '#'):
+		return "inline comment or '#' in value"
+	case trimmed != "" && !strings.ContainsRune(trimmed, '='):
+		return "':' or whitespace separator"
+	case line != trimmed:
+		return "leading or trailing whitespace"
+	}
+	return "other"
+}
+
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %d", name, counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
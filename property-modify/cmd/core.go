@@ -0,0 +1,34 @@
+//go:build gpm_core
+
+package main
+
+import (
+	"fmt"
+	"gpm"
+	"time"
+)
+
+// RemoteCache stands for the remote defaults cache, which is left out of
+// gpm_core builds: every URL fails.
+type RemoteCache struct{}
+
+func NewRemoteCache(dir string, ttl time.Duration, offline bool) (*RemoteCache, error) {
+	return &RemoteCache{}, nil
+}
+
+func (c *RemoteCache) Fetch(url string) ([]byte, error) {
+	return nil, fmt.Errorf("fetching %s needs a build without the gpm_core tag", url)
+}
+
+func printServerHistory(base, key string) int {
+	fmt.Println("Error: -server needs a build without the gpm_core tag")
+	return 2
+}
+
+func remoteStorage(url string) (gpm.Storage, string, error) {
+	return nil, "", fmt.Errorf("reading and writing %s needs a build without the gpm_core tag", url)
+}
+
+func vaultSecret(path, field string) (string, error) {
+	return "", fmt.Errorf("reading %s from Vault needs a build without the gpm_core tag", path)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadData reads a JSON file, or a YAML file for any other extension,
+// into generic maps and slices.
+func loadData(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return decodeData(path, data, v)
+}
+
+// decodeData decodes data read from name, a path or a URL, like loadData.
+func decodeData(name string, data []byte, v any) error {
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		return json.Unmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gpm"
+	"strings"
+)
+
+func isRemote(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// loadDefaults parses the property file at source, a path or an http(s)
+// URL fetched through cache.
+func loadDefaults(source string, cache *RemoteCache) (*gpm.Document, error) {
+	if !isRemote(source) {
+		return gpm.Load(source)
+	}
+	data, err := cache.Fetch(source)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := gpm.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	doc.SetSource(source)
+	return doc, nil
+}
+
+// resolveDefaults applies the -defaults files to m, printing any error,
+// and returns the added keys.
+func resolveDefaults(m *gpm.Modifier) ([]string, error) {
+	if len(defaultsArgs) == 0 {
+		return nil, nil
+	}
+	cache, err := NewRemoteCache(*cacheDir, *defaultsTTL, isOffline())
+	if err != nil {
+		fmt.Println("Error opening the defaults cache:", err)
+		return nil, err
+	}
+	added, err := applyDefaults(m, defaultsArgs, cache)
+	if err != nil {
+		fmt.Println("Error reading defaults:", err)
+		return nil, err
+	}
+	return added, nil
+}
+
+// applyDefaults adds the keys of the defaults files missing from m, the
+// first file that has a key providing its value, and returns the added
+// keys.
+func applyDefaults(m *gpm.Modifier, sources []string, cache *RemoteCache) ([]string, error) {
+	var added []string
+	for _, source := range sources {
+		defaults, err := loadDefaults(source, cache)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range defaults.Keys() {
+			if _, ok := m.Get(key); ok {
+				continue
+			}
+			value, _ := defaults.Get(key)
+			from, _ := defaults.Explain(key)
+			if err := m.SetPropertyFrom(key, value, from); err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+			added = append(added, key)
+		}
+	}
+	return added, nil
+}
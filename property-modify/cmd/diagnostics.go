@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gpm"
+	"gpm/wire"
+	"io"
+	"sort"
+)
+
+const (
+	DIAGNOSTICS_TEXT  = "text"
+	DIAGNOSTICS_JSON  = "json"
+	DIAGNOSTICS_SARIF = "sarif"
+
+	LEVEL_WARNING = "warning"
+	LEVEL_ERROR   = "error"
+
+	RULE_VALIDATE = "validate"
+)
+
+// ruleDescriptions describe the rules in SARIF output.
+var ruleDescriptions = map[string]string{
+	gpm.RULE_TRAILING_WHITESPACE: "Lines should not end with whitespace",
+	gpm.RULE_TAB:                 "Tabs should be converted to spaces",
+	gpm.RULE_BLANK_LINES:         "Too many consecutive blank lines",
+	gpm.RULE_EXPIRED:             "Properties should be removed after their @expires date",
+	gpm.RULE_UNREAD:              "Properties the program never reads should be removed",
+	RULE_VALIDATE:                "Values must satisfy their @type, @min, @max, @pattern and @enum annotations",
+	RULE_REGISTRY:                "Keys and values must be approved by the key registry",
+}
+
+// Diagnostic is a lint issue or validation error of a file.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Rule    string
+	Level   string // "warning" or "error"
+	Message string
+}
+
+func lintDiagnostics(file string, issues []gpm.LintIssue) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(issues))
+	for _, issue := range issues {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:    file,
+			Line:    issue.Line,
+			Rule:    issue.Rule,
+			Level:   LEVEL_WARNING,
+			Message: issue.Message,
+		})
+	}
+	return diagnostics
+}
+
+func validationDiagnostics(file string, errs []*gpm.ValidationError) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(errs))
+	for _, e := range errs {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:    file,
+			Line:    e.Line,
+			Rule:    RULE_VALIDATE,
+			Level:   LEVEL_ERROR,
+			Message: fmt.Sprintf("%s: %s", e.Key, e.Message),
+		})
+	}
+	return diagnostics
+}
+
+// writeDiagnostics prints diagnostics as "file:line: [rule] message" lines,
+// a wire.Report or a SARIF 2.1.0 log.
+func writeDiagnostics(w io.Writer, format string, diagnostics []Diagnostic) error {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	switch format {
+	case DIAGNOSTICS_TEXT:
+		for _, d := range diagnostics {
+			fmt.Fprintf(w, "%s:%d: [%s] %s\n", d.File, d.Line, d.Rule, d.Message)
+		}
+		return nil
+	case DIAGNOSTICS_JSON:
+		report := make([]wire.Diagnostic, 0, len(diagnostics))
+		for _, d := range diagnostics {
+			report = append(report, wire.Diagnostic(d))
+		}
+		return wire.Write(w, wire.NewReport(report))
+	case DIAGNOSTICS_SARIF:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sarifLog(diagnostics))
+	}
+	return fmt.Errorf("unknown diagnostics format %q, expected %s, %s or %s", format, DIAGNOSTICS_TEXT, DIAGNOSTICS_JSON, DIAGNOSTICS_SARIF)
+}
+
+func sarifLog(diagnostics []Diagnostic) map[string]any {
+	var ruleIDs []string
+	seen := make(map[string]bool)
+	results := []map[string]any{}
+	for _, d := range diagnostics {
+		if !seen[d.Rule] {
+			seen[d.Rule] = true
+			ruleIDs = append(ruleIDs, d.Rule)
+		}
+		location := map[string]any{
+			"artifactLocation": map[string]string{"uri": d.File},
+		}
+		if d.Line > 0 {
+			// a missing key is reported on the whole file, line 0
+			location["region"] = map[string]int{"startLine": d.Line}
+		}
+		results = append(results, map[string]any{
+			"ruleId":    d.Rule,
+			"level":     d.Level,
+			"message":   map[string]string{"text": d.Message},
+			"locations": []map[string]any{{"physicalLocation": location}},
+		})
+	}
+	sort.Strings(ruleIDs)
+
+	rules := []map[string]any{}
+	for _, id := range ruleIDs {
+		rules = append(rules, map[string]any{
+			"id":               id,
+			"shortDescription": map[string]string{"text": ruleDescriptions[id]},
+		})
+	}
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "gpm",
+					"version":        VERSION,
+					"informationUri": "https://github.com/holmeszyx/buidingscript",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
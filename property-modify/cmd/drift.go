@@ -0,0 +1,290 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DriftConfig lists the files watched by the drift command and their
+// desired states:
+//
+//	files:
+//	  - path: /home/dev/.gradle/gradle.properties
+//	    state: policy/gradle.yaml
+type DriftConfig struct {
+	Files []DriftFile `yaml:"files" json:"files"`
+}
+
+type DriftFile struct {
+	Path  string `yaml:"path" json:"path"`
+	State string `yaml:"state" json:"state"`
+}
+
+// DriftEvent is written for every drifted key found by a check.
+type DriftEvent struct {
+	Time       time.Time `json:"time"`
+	File       string    `json:"file"`
+	Key        string    `json:"key"`
+	Drift      string    `json:"drift"` // the operation fixing it
+	Outcome    string    `json:"outcome"`
+	Remediated bool      `json:"remediated"`
+}
+
+// driftMetrics are the counters of a file exposed at /metrics.
+type driftMetrics struct {
+	checks       int
+	errors       int
+	drifts       int
+	remediations int
+	inSync       bool
+}
+
+// DriftDaemon re-checks files against their desired state whenever the
+// file or the state changed, detected by polling their modification
+// times, and at least every full interval.
+type DriftDaemon struct {
+	files     []DriftFile
+	remediate bool
+	full      time.Duration
+
+	events   io.Writer
+	history  *ValueHistory
+	mu       sync.Mutex
+	metrics  map[string]*driftMetrics
+	modTimes map[string]time.Time
+	lastFull time.Time
+}
+
+func init() {
+	registerCommand(Command{"drift", "Keep checking property files against their desired state", runDrift})
+	serverFeatures = append(serverFeatures, "drift", "drift-metrics")
+}
+
+func runDrift(args []string) int {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	configFile := fs.String("config", "", "YAML or JSON list of the files to watch and their desired state files")
+	interval := fs.Duration("interval", 5*time.Second, "How often modification times are polled for changes")
+	full := fs.Duration("full", time.Hour, "How often every file is checked even if nothing changed")
+	remediate := fs.Bool("remediate", false, "Converge drifted files to their desired state")
+	eventsFile := fs.String("events", "-", "Append a JSON line per drifted key to this file, '-' for stdout")
+	metricsAddr := fs.String("metrics", "", "Serve Prometheus metrics at http://<addr>/metrics and the last drifted values of a key at /history/{key}")
+	historySize := fs.Int("history", 20, "Drifted values remembered per key for /history/{key}")
+	once := fs.Bool("once", false, "Check every file once and exit 1 if any drifted")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify drift [options]")
+		fmt.Println("Keep checking property files against their desired state (see apply).")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *configFile == "" {
+		fs.Usage()
+		return 2
+	}
+
+	var config DriftConfig
+	if err := loadData(*configFile, &config); err != nil {
+		fmt.Println("Error reading drift config:", err)
+		return 2
+	}
+	d := &DriftDaemon{
+		files:     config.Files,
+		remediate: *remediate,
+		full:      *full,
+		events:    os.Stdout,
+		metrics:   make(map[string]*driftMetrics),
+		modTimes:  make(map[string]time.Time),
+		history:   NewValueHistory(*historySize),
+	}
+	if *eventsFile != "-" {
+		file, err := os.OpenFile(*eventsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Println("Error opening events file:", err)
+			return 2
+		}
+		defer file.Close()
+		d.events = file
+	}
+
+	if *once {
+		if d.checkAll(time.Now()) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	if *metricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", d)
+			mux.Handle("GET /history/{key}", d.history)
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Println("Error serving metrics:", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for now := time.Now(); ; now = <-ticker.C {
+		d.checkAll(now)
+	}
+}
+
+// checkAll checks the files that changed, or every file if a full
+// interval passed, and returns the number of drifted keys.
+func (d *DriftDaemon) checkAll(now time.Time) int {
+	d.mu.Lock()
+	full := now.Sub(d.lastFull) >= d.full
+	if full {
+		d.lastFull = now
+	}
+	d.mu.Unlock()
+
+	drifts := 0
+	for _, f := range d.files {
+		fileChanged, stateChanged := d.changed(f.Path), d.changed(f.State)
+		if !full && !fileChanged && !stateChanged {
+			continue
+		}
+		n, err := d.check(f, now)
+		if err != nil {
+			fmt.Printf("Error checking %s: %v\n", f.Path, err)
+		}
+		drifts += n
+	}
+	return drifts
+}
+
+// changed reports whether the modification time of path changed since
+// the last call.
+func (d *DriftDaemon) changed(path string) bool {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	last, seen := d.modTimes[path]
+	d.modTimes[path] = modTime
+	return !seen || !last.Equal(modTime)
+}
+
+// check compares a file with its desired state, remediating it if asked,
+// and returns the number of drifted keys.
+func (d *DriftDaemon) check(f DriftFile, now time.Time) (int, error) {
+	d.mu.Lock()
+	m := d.metrics[f.Path]
+	if m == nil {
+		m = &driftMetrics{}
+		d.metrics[f.Path] = m
+	}
+	m.checks++
+	d.mu.Unlock()
+
+	drift, found, err := d.converge(f)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		m.errors++
+		return 0, err
+	}
+	m.drifts += len(drift)
+	m.inSync = len(drift) == 0 || d.remediate
+	if d.remediate {
+		m.remediations += len(drift)
+	}
+
+	for _, e := range drift {
+		value, ok := found[e.Op.Key]
+		d.history.Record(e.Op.Key, ValueRecord{
+			Time:    now,
+			File:    f.Path,
+			Value:   value,
+			Removed: !ok,
+		})
+		line, err := json.Marshal(DriftEvent{
+			Time:       now,
+			File:       f.Path,
+			Key:        e.Op.Key,
+			Drift:      e.Op.String(),
+			Outcome:    e.Outcome,
+			Remediated: d.remediate,
+		})
+		if err != nil {
+			continue
+		}
+		if _, err := d.events.Write(append(line, '\n')); err != nil {
+			fmt.Println("Error writing drift event:", err)
+		}
+	}
+	return len(drift), nil
+}
+
+// converge checks a file against its desired state, remediating it if
+// asked, and returns the drift and the values found in the file.
+func (d *DriftDaemon) converge(f DriftFile) ([]Explanation, map[string]string, error) {
+	state, err := loadState(f.State)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := gpm.Load(f.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	props := doc.Props()
+	found := make(map[string]string, len(props))
+	for _, p := range props {
+		if p.Key() != "" {
+			found[p.Key()] = p.Value()
+		}
+	}
+	modifier := doc.Modifier
+	drift := state.converge(modifier, propertyKeys(props))
+	if len(drift) > 0 && d.remediate {
+		if err := saveOutput(f.Path, modifier); err != nil {
+			return nil, nil, err
+		}
+		// our own write is not a change to check again
+		d.changed(f.Path)
+	}
+	return drift, found, nil
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (d *DriftDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	paths := make([]string, 0, len(d.metrics))
+	for p := range d.metrics {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metric := func(name, help, typ string, value func(m *driftMetrics) int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, p := range paths {
+			fmt.Fprintf(w, "%s{file=%q} %d\n", name, p, value(d.metrics[p]))
+		}
+	}
+	metric("gpm_drift_checks_total", "Checks of the file against its desired state.", "counter", func(m *driftMetrics) int { return m.checks })
+	metric("gpm_drift_errors_total", "Checks that failed.", "counter", func(m *driftMetrics) int { return m.errors })
+	metric("gpm_drift_keys_total", "Drifted keys found.", "counter", func(m *driftMetrics) int { return m.drifts })
+	metric("gpm_drift_remediations_total", "Drifted keys fixed.", "counter", func(m *driftMetrics) int { return m.remediations })
+	metric("gpm_drift_in_sync", "Whether the file matched its desired state after the last check.", "gauge", func(m *driftMetrics) int {
+		if m.inSync {
+			return 1
+		}
+		return 0
+	})
+}
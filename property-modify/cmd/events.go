@@ -0,0 +1,102 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// ChangeEvent is broadcast to the /events subscribers for every change
+// made through the server.
+type ChangeEvent struct {
+	Type     string `json:"type"` // "set" or "rm"
+	Key      string `json:"key"`
+	OldValue string `json:"old,omitempty"`
+	NewValue string `json:"new,omitempty"`
+	Existed  bool   `json:"existed"`
+	Actor    string `json:"actor"`
+}
+
+// Broker fans change events out to server-sent event streams.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan ChangeEvent]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[chan ChangeEvent]struct{}),
+	}
+}
+
+// Publish sends e to every subscriber. Subscribers that don't keep up
+// miss events rather than blocking the writer.
+func (b *Broker) Publish(e ChangeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *Broker) subscribe() chan ChangeEvent {
+	ch := make(chan ChangeEvent, 64)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *Broker) unsubscribe(ch chan ChangeEvent) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// ServeHTTP streams the change events as server-sent events until the
+// client goes away.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// actor names the client making a request: its token name, the X-Actor
+// header or its address.
+func actor(r *http.Request) string {
+	if info := infoOf(r); info != nil && info.token != nil {
+		return info.token.Name
+	}
+	if a := r.Header.Get("X-Actor"); a != "" {
+		return a
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matrix is the definition read by the expand command:
+//
+//	dimensions:
+//	  flavor: [free, paid]
+//	  abi: [arm64-v8a, x86_64]
+//	set:
+//	  app.id: com.example.${flavor}
+//	output: build/${flavor}-${abi}.properties
+type Matrix struct {
+	Dimensions Dimensions        `yaml:"dimensions"`
+	Set        map[string]string `yaml:"set"`
+	Output     string            `yaml:"output"`
+}
+
+// Dimension is one axis of a matrix.
+type Dimension struct {
+	Name   string
+	Values []string
+}
+
+// Dimensions keeps the order the dimensions were declared in.
+type Dimensions []Dimension
+
+func (d *Dimensions) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: dimensions must be a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		dim := Dimension{Name: node.Content[i].Value}
+		if err := node.Content[i+1].Decode(&dim.Values); err != nil {
+			return err
+		}
+		*d = append(*d, dim)
+	}
+	return nil
+}
+
+// Combinations returns every combination of the dimension values, the
+// last dimension varying fastest.
+func (d Dimensions) Combinations() []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, dim := range d {
+		var next []map[string]string
+		for _, c := range combinations {
+			for _, v := range dim.Values {
+				n := make(map[string]string, len(c)+1)
+				for k, cv := range c {
+					n[k] = cv
+				}
+				n[dim.Name] = v
+				next = append(next, n)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+func runExpand(args []string) int {
+	fs := flag.NewFlagSet("expand", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Base property file, ${dimension} in keys and values is substituted")
+	matrixFile := fs.String("matrix", "", "YAML (or JSON) matrix definition")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify expand [options]")
+		fmt.Println("Write one property file per combination of the matrix dimensions.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *matrixFile == "" {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(*matrixFile)
+	if err != nil {
+		fmt.Println("Error reading matrix:", err)
+		return 2
+	}
+	var matrix Matrix
+	if err := yaml.Unmarshal(data, &matrix); err != nil {
+		fmt.Println("Error parsing matrix:", err)
+		return 2
+	}
+	if len(matrix.Dimensions) == 0 {
+		fmt.Println("Error: the matrix has no dimensions")
+		return 2
+	}
+	if matrix.Output == "" {
+		ext := filepath.Ext(*input)
+		matrix.Output = (*input)[:len(*input)-len(ext)]
+		for _, dim := range matrix.Dimensions {
+			matrix.Output += "-${" + dim.Name + "}"
+		}
+		matrix.Output += ext
+	}
+
+	setKeys := make([]string, 0, len(matrix.Set))
+	for k := range matrix.Set {
+		setKeys = append(setKeys, k)
+	}
+	sort.Strings(setKeys)
+
+	for _, vars := range matrix.Dimensions.Combinations() {
+		doc, err := parseInput(*input)
+		if err != nil {
+			return 2
+		}
+		modifier := doc.Modifier
+		modifier.Substitute(vars)
+		for _, k := range setKeys {
+			if err := modifier.SetProperty(gpm.SubstituteString(k, vars), gpm.SubstituteString(matrix.Set[k], vars), nil); err != nil {
+				fmt.Println("Error setting property:", err)
+				return 2
+			}
+		}
+
+		output := gpm.SubstituteString(matrix.Output, vars)
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			fmt.Println("Error creating output directory:", err)
+			return 1
+		}
+		if err := saveOutput(output, modifier); err != nil {
+			return 1
+		}
+		fmt.Println("Expanded", output)
+	}
+	return 0
+}
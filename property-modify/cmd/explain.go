@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Outcomes of an operation reported by -explain.
+const (
+	OUTCOME_CREATED  = "created"
+	OUTCOME_CHANGED  = "changed"
+	OUTCOME_REMOVED  = "removed"
+	OUTCOME_RENAMED  = "renamed"
+	OUTCOME_DISABLED = "disabled"
+	OUTCOME_ENABLED  = "enabled"
+	OUTCOME_NO_OP    = "no-op"
+)
+
+var outcomeOrder = []string{OUTCOME_CREATED, OUTCOME_CHANGED, OUTCOME_REMOVED, OUTCOME_RENAMED, OUTCOME_DISABLED, OUTCOME_ENABLED, OUTCOME_NO_OP}
+
+// Explanation is what an operation did to the file.
+type Explanation struct {
+	Op      Operation
+	Outcome string
+}
+
+// explain tells the outcome of op from whether its key existed before
+// and whether the file changed.
+func explain(op Operation, existed, changed bool) Explanation {
+	e := Explanation{Op: op, Outcome: OUTCOME_NO_OP}
+	switch {
+	case !changed:
+	case op.Type == OP_TYPE_RM:
+		e.Outcome = OUTCOME_REMOVED
+	case op.Type == OP_TYPE_RENAME:
+		e.Outcome = OUTCOME_RENAMED
+	case op.Type == OP_TYPE_DISABLE:
+		e.Outcome = OUTCOME_DISABLED
+	case op.Type == OP_TYPE_ENABLE:
+		e.Outcome = OUTCOME_ENABLED
+	case existed:
+		e.Outcome = OUTCOME_CHANGED
+	default:
+		e.Outcome = OUTCOME_CREATED
+	}
+	return e
+}
+
+// writeExplanations prints the outcome of every operation and a summary:
+//
+//	changed  set app.version=1.0.1
+//	no-op    rm app.id
+//	2 operations: 1 changed, 1 no-op
+func writeExplanations(w io.Writer, explanations []Explanation) {
+	counts := make(map[string]int)
+	for _, e := range explanations {
+		counts[e.Outcome]++
+		fmt.Fprintf(w, "%-8s %s\n", e.Outcome, e.Op)
+	}
+	fmt.Fprintf(w, "%d operations", len(explanations))
+	sep := ": "
+	for _, outcome := range outcomeOrder {
+		if counts[outcome] > 0 {
+			fmt.Fprintf(w, "%s%d %s", sep, counts[outcome], outcome)
+			sep = ", "
+		}
+	}
+	fmt.Fprintln(w)
+}
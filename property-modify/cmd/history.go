@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+)
+
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file tracked by git")
+	server := fs.String("server", "", "List the last values of key remembered by a serve or drift -metrics server at this URL instead, e.g. http://localhost:8080")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify history [options] key")
+		fmt.Println("List the commits that changed the value of key, oldest first.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	key := fs.Arg(0)
+
+	if *server != "" {
+		if isOffline() {
+			fmt.Printf("Error: -server needs the network, but %s is set\n", OFFLINE_ENV)
+			return 2
+		}
+		return printServerHistory(*server, key)
+	}
+
+	changes, err := gpm.GitHistory(*input, key)
+	if err != nil {
+		fmt.Println("Error reading history:", err)
+		return 1
+	}
+	for _, c := range changes {
+		var change string
+		switch {
+		case !c.Existed:
+			change = fmt.Sprintf("added %q", c.NewValue)
+		case !c.Exists:
+			change = fmt.Sprintf("removed %q", c.OldValue)
+		default:
+			change = fmt.Sprintf("%q -> %q", c.OldValue, c.NewValue)
+		}
+		fmt.Printf("%s %s %s: %s (%s)\n", c.Date, c.Commit[:7], c.Author, change, c.Subject)
+	}
+	return 0
+}
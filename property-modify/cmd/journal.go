@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+const JOURNAL_EXT = ".journal"
+
+// Journal is an append-only log of the operations applied to a file since
+// its last save, one JSON object per line. Every operation is synced to
+// disk before it is applied, so a session that dies before saving can be
+// recovered by replaying the journal.
+type Journal struct {
+	path string
+	file *os.File
+}
+
+// OpenJournal opens the journal of the property file at path and returns
+// the operations left in it by a session that didn't save.
+func OpenJournal(path string) (*Journal, []Operation, error) {
+	j := &Journal{path: path + JOURNAL_EXT}
+	pending, err := j.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	j.file, err = os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return j, pending, nil
+}
+
+func (j *Journal) read() ([]Operation, error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var operations []Operation
+	buf := bufio.NewScanner(file)
+	for buf.Scan() {
+		var op Operation
+		if err := json.Unmarshal(buf.Bytes(), &op); err != nil {
+			// a torn write of the last entry, it was never acknowledged
+			break
+		}
+		operations = append(operations, op)
+	}
+	return operations, buf.Err()
+}
+
+// Append durably records op.
+func (j *Journal) Append(op Operation) error {
+	line, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return j.file.Sync()
+}
+
+// Reset empties the journal after the file was saved.
+func (j *Journal) Reset() error {
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Remove closes and deletes the journal.
+func (j *Journal) Remove() error {
+	j.file.Close()
+	return os.Remove(j.path)
+}
+
+// Close closes the journal, keeping its operations for the next session.
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gpm"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LSP error codes
+const (
+	LSP_METHOD_NOT_FOUND = -32601
+	LSP_INVALID_PARAMS   = -32602
+)
+
+// LSP diagnostic severities
+const (
+	LSP_ERROR   = 1
+	LSP_WARNING = 2
+)
+
+type lspRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+type lspNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes map[string][]lspTextEdit `json:"changes"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCodeAction struct {
+	Title string           `json:"title"`
+	Kind  string           `json:"kind"`
+	Edit  lspWorkspaceEdit `json:"edit"`
+}
+
+type lspTextDocument struct {
+	URI     string `json:"uri"`
+	Text    string `json:"text,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+// LanguageServer speaks the Language Server Protocol over stdio for
+// .properties files: document sync, hover with the comments of a key,
+// lint and validation diagnostics, a remove property and a normalize code
+// action, and key renaming.
+type LanguageServer struct {
+	in  *bufio.Reader
+	out io.Writer
+	// outMu serializes the messages written to out
+	outMu sync.Mutex
+	docs  map[string]string
+	// normalize are the options of the lint diagnostics and the
+	// normalize action
+	normalize gpm.NormalizeOptions
+	shutdown  bool
+}
+
+func runLSP(args []string) int {
+	if len(args) > 0 {
+		fmt.Println("Usage: property-modify lsp")
+		fmt.Println("Run a language server for .properties files on stdin and stdout.")
+		return 2
+	}
+	s := &LanguageServer{
+		in:        bufio.NewReader(os.Stdin),
+		out:       os.Stdout,
+		docs:      make(map[string]string),
+		normalize: gpm.DefaultNormalizeOptions(),
+	}
+	return s.Run()
+}
+
+// Run serves requests until the exit notification or end of input.
+func (s *LanguageServer) Run() int {
+	tp := textproto.NewReader(s.in)
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return 1
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return 1
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(s.in, body); err != nil {
+			return 1
+		}
+
+		var req lspRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, rpcErr := s.handle(req)
+		if req.ID != nil {
+			s.send(lspResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr})
+		}
+	}
+}
+
+func (s *LanguageServer) send(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *LanguageServer) handle(req lspRequest) (any, *lspError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // full
+				"hoverProvider":      true,
+				"codeActionProvider": true,
+				"renameProvider":     true,
+			},
+			"serverInfo": map[string]string{"name": "gpm", "version": VERSION},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.publishDiagnostics(params.TextDocument.URI, []lspDiagnostic{})
+	case "textDocument/hover":
+		var params lspPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(params), nil
+	case "textDocument/codeAction":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+			Range        lspRange        `json:"range"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.codeActions(params.TextDocument.URI, params.Range), nil
+	case "textDocument/rename":
+		var params struct {
+			lspPositionParams
+			NewName string `json:"newName"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.rename(params.lspPositionParams, params.NewName)
+	default:
+		if req.ID != nil {
+			return nil, &lspError{Code: LSP_METHOD_NOT_FOUND, Message: "method not found: " + req.Method}
+		}
+	}
+	return nil, nil
+}
+
+func invalidParams(err error) *lspError {
+	return &lspError{Code: LSP_INVALID_PARAMS, Message: err.Error()}
+}
+
+func (s *LanguageServer) update(uri, text string) {
+	s.docs[uri] = text
+	s.publishDiagnostics(uri, s.diagnostics(text))
+}
+
+func (s *LanguageServer) publishDiagnostics(uri string, diagnostics []lspDiagnostic) {
+	s.send(lspNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]any{
+			"uri":         uri,
+			"diagnostics": diagnostics,
+		},
+	})
+}
+
+// parse parses a document, errors can't happen when reading from a
+// string.
+func parse(text string) []gpm.Property {
+	parser := gpm.NewParser()
+	parser.Parse(strings.NewReader(text))
+	return parser.GetProps()
+}
+
+func lineRange(lines []string, lineNum int) lspRange {
+	line := lineNum - 1
+	end := 0
+	if line >= 0 && line < len(lines) {
+		end = len([]rune(lines[line]))
+	}
+	return lspRange{
+		Start: lspPosition{Line: line},
+		End:   lspPosition{Line: line, Character: end},
+	}
+}
+
+func (s *LanguageServer) diagnostics(text string) []lspDiagnostic {
+	lines := strings.Split(text, "\n")
+	diagnostics := []lspDiagnostic{}
+
+	issues, _ := gpm.Lint(strings.NewReader(text), s.normalize)
+	props := parse(text)
+	issues = append(issues, gpm.LintExpired(props, time.Now())...)
+	for _, issue := range issues {
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lineRange(lines, issue.Line),
+			Severity: LSP_WARNING,
+			Code:     issue.Rule,
+			Source:   "gpm",
+			Message:  issue.Message,
+		})
+	}
+
+	modifier := gpm.NewModifier(props)
+	for _, e := range modifier.Validate() {
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lineRange(lines, e.Line),
+			Severity: LSP_ERROR,
+			Code:     "validate",
+			Source:   "gpm",
+			Message:  fmt.Sprintf("%s: %s", e.Key, e.Message),
+		})
+	}
+	return diagnostics
+}
+
+// propertyAt returns the property on a 0-based line of a document.
+func (s *LanguageServer) propertyAt(uri string, line int) (gpm.Property, bool) {
+	text, ok := s.docs[uri]
+	if !ok {
+		return gpm.Property{}, false
+	}
+	for _, p := range parse(text) {
+		if p.LineNum() <= line+1 && line+1 < p.LineNum()+p.Lines() && p.Key() != "" {
+			return p, true
+		}
+	}
+	return gpm.Property{}, false
+}
+
+func (s *LanguageServer) hover(params lspPositionParams) any {
+	p, ok := s.propertyAt(params.TextDocument.URI, params.Position.Line)
+	if !ok {
+		return nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s** = `%s`", p.Key(), p.Value())
+	if p.Doc() != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(p.Doc())
+	}
+	if p.Comment() != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(p.Comment())
+	}
+	return map[string]any{
+		"contents": map[string]string{
+			"kind":  "markdown",
+			"value": sb.String(),
+		},
+	}
+}
+
+func (s *LanguageServer) codeActions(uri string, r lspRange) []lspCodeAction {
+	actions := []lspCodeAction{}
+	text, ok := s.docs[uri]
+	if !ok {
+		return actions
+	}
+
+	if p, ok := s.propertyAt(uri, r.Start.Line); ok {
+		line := p.LineNum() - 1
+		actions = append(actions, lspCodeAction{
+			Title: "Remove " + p.Key(),
+			Kind:  "quickfix",
+			Edit: lspWorkspaceEdit{Changes: map[string][]lspTextEdit{
+				uri: {{
+					Range:   lspRange{Start: lspPosition{Line: line}, End: lspPosition{Line: line + p.Lines()}},
+					NewText: "",
+				}},
+			}},
+		})
+	}
+
+	modifier := gpm.NewModifier(parse(text))
+	if modifier.Normalize(s.normalize) > 0 {
+		lines := strings.Count(text, "\n") + 1
+		actions = append(actions, lspCodeAction{
+			Title: "Normalize whitespace",
+			Kind:  "source.fixAll",
+			Edit: lspWorkspaceEdit{Changes: map[string][]lspTextEdit{
+				uri: {{
+					Range:   lspRange{End: lspPosition{Line: lines}},
+					NewText: modifier.Text(),
+				}},
+			}},
+		})
+	}
+	return actions
+}
+
+func (s *LanguageServer) rename(params lspPositionParams, newName string) (any, *lspError) {
+	uri := params.TextDocument.URI
+	p, ok := s.propertyAt(uri, params.Position.Line)
+	if !ok {
+		return nil, &lspError{Code: LSP_INVALID_PARAMS, Message: "no property at this position"}
+	}
+	modifier := gpm.NewModifier(parse(s.docs[uri]))
+	if _, exists := modifier.Get(newName); exists {
+		return nil, &lspError{Code: LSP_INVALID_PARAMS, Message: fmt.Sprintf("key %q already exists", newName)}
+	}
+
+	line := strings.Split(s.docs[uri], "\n")[p.LineNum()-1]
+	start := len([]rune(line[:strings.Index(line, p.Key())]))
+	return lspWorkspaceEdit{Changes: map[string][]lspTextEdit{
+		uri: {{
+			Range: lspRange{
+				Start: lspPosition{Line: p.LineNum() - 1, Character: start},
+				End:   lspPosition{Line: p.LineNum() - 1, Character: start + len([]rune(p.Key()))},
+			},
+			NewText: newName,
+		}},
+	}}, nil
+}
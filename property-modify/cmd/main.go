@@ -1,25 +1,64 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"gpm"
+	"gpm/bridge"
+	"gpm/wire"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"syscall"
+	"time"
 )
 
 const (
-	VERSION     = "0.0.1"
-	OP_TYPE_SET = "set"
-	OP_TYPE_RM  = "rm"
+	VERSION        = "0.0.1"
+	OP_TYPE_SET    = "set"
+	OP_TYPE_RM     = "rm"
+	OP_TYPE_RENAME = "rename"
+	// OP_TYPE_DISABLE comments out a property, OP_TYPE_ENABLE restores it
+	OP_TYPE_DISABLE = "disable"
+	OP_TYPE_ENABLE  = "enable"
+
+	TIMESTAMP_KEEP    = "keep"
+	TIMESTAMP_DROP    = "drop"
+	TIMESTAMP_REFRESH = "refresh"
+
+	LINE_ENDING_KEEP = "keep"
+	LINE_ENDING_LF   = "lf"
+	LINE_ENDING_CRLF = "crlf"
+
+	TRAILING_NEWLINE_KEEP   = "keep"
+	TRAILING_NEWLINE_ADD    = "add"
+	TRAILING_NEWLINE_REMOVE = "remove"
+
+	// INPUT_SNAPSHOT labels the input file state for -diff
+	INPUT_SNAPSHOT = "input"
+
+	// OFFLINE_ENV is the environment variable that sets -offline for
+	// every command
+	OFFLINE_ENV = "GPM_OFFLINE"
 )
 
 type Operation struct {
-	Type    string // "set" or "rm"
-	Key     string
-	Value   string // only used for "set" operations
-	Comment string // only used for "set" operations
+	Type    string `json:"type"` // "set", "rm", "rename", "disable" or "enable"
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`   // only used for "set" operations
+	Comment string `json:"comment,omitempty"` // only used for "set" operations
+	NewKey  string `json:"newKey,omitempty"`  // only used for "rename" operations
+	// After and Before place a new key set by a "set" operation next to an
+	// existing key instead of at the end of the file
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
 }
 
 type StringSlice []string
@@ -34,22 +73,147 @@ func (s *StringSlice) Set(value string) error {
 }
 
 var (
-	inputFile  = flag.String("input", "local.properties", "Input property file")
-	outputFile = flag.String("output", "", "Output property file, default is the same file as input")
-	setArgs    StringSlice
-	rmArgs     StringSlice
+	inputFile         = flag.String("input", "local.properties", "Input property file")
+	outputFile        = flag.String("output", "", "Output property file, default is the same file as input")
+	outputDir         = flag.String("output-dir", "", "Directory to write the output to, under its file name, when its own directory is read-only or not writable, e.g. a source tree mounted read-only in CI")
+	mergeFile         = flag.String("merge", "", "Merge the properties of this file or URL into the input: the keys it adds are set, and -prefer resolves the keys both set to different values")
+	prefer            = flag.String("prefer", "", "Value -merge keeps for a key both files set differently: ours, theirs, newest (the value changed last, see -modified-from) or ask (show both and prompt), fails on conflicts if unset")
+	modifiedFrom      = flag.String("modified-from", MODIFIED_FILE, "Where -prefer newest reads when values were last changed: file (modification time of the files), annotation (@modified comments) or git (git blame)")
+	setBlock          = flag.String("set-block", "", "Set every 'key=value' line of this block of property lines, - to read it from stdin, e.g. a pasted chunk of a file or a here-doc")
+	opsStdin          = flag.Bool("ops-stdin", false, "Read operations from stdin, one per line: 'set key=value', 'rm key', 'rename old new', 'disable key' or 'enable key'. They are applied after the other operations")
+	preserveLayout    = flag.Bool("preserve-layout", false, "Keep the indentation, spacing and line endings of the lines the operations don't change byte-identical")
+	normalize         = flag.Bool("normalize", false, "Strip trailing whitespace, convert tabs and collapse blank lines")
+	commentPrefixes   = flag.String("comment-prefixes", "", "Comma-separated prefixes starting comments instead of '#' and '!', e.g. ';,//' for INI-like files; the first one is used for added comments")
+	encoding          = flag.String("encoding", gpm.ENCODING_AUTO, "Encoding of the input file, kept when saving: utf8, latin1 (ISO-8859-1, like java.util.Properties) or auto to read it as UTF-8 if it is valid UTF-8")
+	lineEnding        = flag.String("line-ending", LINE_ENDING_KEEP, "Line ending of the saved file: keep (the one of most lines, for the changed lines), lf or crlf (every line)")
+	duplicates        = flag.String("duplicates", gpm.DUPLICATES_KEEP_ALL, "What to do with keys set on several lines of the input: keep-all (the last one wins), keep-first, keep-last (drop the other lines) or error")
+	commentBlocks     = flag.Bool("comment-blocks", false, "Treat the comment lines directly above a property as its documentation: -rm removes them with it")
+	strict            = flag.Bool("strict", false, "Fail on malformed lines, like a line without '=' or a key that isn't read back as written, reporting every one with its line and column")
+	quotedValues      = flag.Bool("quoted-values", false, "Read values between single or double quotes, e.g. 'key = \"  padded  \"', without the quotes and with their whitespace, and quote values set with whitespace at either end")
+	javaWhitespace    = flag.Bool("java-whitespace", false, "Keep the whitespace at the end of values, like java.util.Properties: 'key=value  ' is 'value  '")
+	javaSeparators    = flag.Bool("java-separators", false, "Also end keys at the first ':' or whitespace, like java.util.Properties: 'key: value' and 'key value'")
+	lint              = flag.Bool("lint", false, "Report whitespace issues -normalize would fix and expired properties (and -validate errors), then exit without modifying the file")
+	storeTimestamp    = flag.String("store-timestamp", "keep", "What to do with the Properties.store() timestamp comment at the top: keep, drop or refresh (adds it if missing)")
+	tabWidth          = flag.Int("tab-width", 0, "Convert tabs to this many spaces when normalizing (0 keeps tabs)")
+	maxBlankLines     = flag.Int("max-blank-lines", 2, "Maximum number of consecutive blank lines kept when normalizing")
+	insertAfter       = flag.String("after", "", "Add the keys -set creates right after this key, in order, instead of at the end of the file")
+	insertBefore      = flag.String("before", "", "Add the keys -set creates before this key and the comment lines above it, instead of at the end of the file")
+	renameOverwrite   = flag.Bool("rename-overwrite", false, "Let -rename replace the line of a new key that is already set instead of failing")
+	stripBlankLines   = flag.Bool("strip-blank-lines", false, "Remove every blank line")
+	usageReport       = flag.String("usage", "", "JSON usage report of a program reading the input with a gpm.Usage: -lint reports the keys it never read")
+	pruneExpired      = flag.Bool("prune-expired", false, "Remove properties whose @expires date has passed")
+	sortRefs          = flag.Bool("sort-refs", false, "Move properties so that every key comes after the keys it references with ${key}")
+	registry          = flag.String("registry", "", "Key registry of the organization, a YAML or JSON file or an http(s) URL cached like -defaults: the keys it gives a default are added when missing, and -validate checks the keys and values against it")
+	cacheDir          = flag.String("cache-dir", "", "Directory of the -defaults URL cache, default is gpm in the user cache directory")
+	defaultsTTL       = flag.Duration("defaults-ttl", time.Hour, "How long a -defaults URL is used from the cache before it is revalidated with the host")
+	diagnosticsFormat = flag.String("diagnostics", "text", "Output format of -lint and -validate findings: text, json or sarif")
+	validate          = flag.Bool("validate", false, "Check values against their @type, @min, @max, @pattern and @enum annotations, nothing is saved on failure")
+	maxValueLength    = flag.Int("max-value-length", 0, "Reject values longer than this many characters when setting and validating (0 for no limit)")
+	asciiOutput       = flag.String("ascii-output", "", "Write pure ASCII, reporting every changed line: escape (\\uXXXX) or transliterate (é as e, escaping the characters without a look-alike)")
+	asciiOnly         = flag.Bool("ascii-only", false, "Reject values with non-ASCII characters when setting and validating")
+	noControlChars    = flag.Bool("no-control-chars", false, "Reject values with control characters other than tab when setting and validating")
+	minimalDiff       = flag.Bool("minimal-diff", false, "Fail without saving unless the output differs from the input only on the lines of the keys the operations changed")
+	dumpAST           = flag.String("dump-ast", "", "Print the parsed model of every line in this format (json) and exit")
+	includeDisabled   = flag.Bool("include-disabled", false, "With -list, also list the commented-out properties, like '#key=value', flagged disabled")
+	provenance        = flag.Bool("provenance", false, "With -list, report the file and line each value is set at, e.g. a -defaults file")
+	list              = flag.Bool("list", false, "Print the properties as JSON and exit")
+	genGo             = flag.String("gen-go", "", "Print a Go source file of this package with one constant per property and exit")
+	explainOps        = flag.Bool("explain", false, "Report whether each operation created, changed, removed or renamed a key or was a no-op, with a summary")
+	diff              = flag.Bool("diff", false, "Print the changes made by the operations as JSON instead of saving the file")
+	onlyKeys          = flag.String("only-keys", "", "Write only the keys matching these comma separated keys, path.Match patterns or re: regular expressions, e.g. 'sdk.*,ndk.*', with their comments to -output")
+	failOnChange      = flag.Bool("fail-on-change", false, "Exit 1 if the operations changed the file, e.g. to detect drift with -diff")
+	failOnNoChange    = flag.Bool("fail-on-no-change", false, "Exit 1 if the operations left the file unchanged")
+	trailingNewline   = flag.String("trailing-newline", TRAILING_NEWLINE_KEEP, "Whether the saved file ends with a line ending: keep (like the input), add or remove")
+	unicodeEscapes    = flag.Bool("unicode-escapes", false, "Decode \\uXXXX escapes in keys and values when reading and write non-ASCII characters as \\uXXXX, for java.util.Properties readers")
+	offline           = flag.Bool("offline", false, "Fail instead of using the network, e.g. for -defaults URLs that are not cached (or set GPM_OFFLINE=1, which applies to the subcommands too)")
+	wrapColumn        = flag.Int("wrap", 0, "Wrap values of lines longer than this many columns with backslash continuations (0 disables)")
+	setArgs           StringSlice
+	rmArgs            StringSlice
+	renameArgs        StringSlice
+	setCommentArgs    StringSlice
+	rmCommentArgs     StringSlice
+	disableArgs       StringSlice
+	enableArgs        StringSlice
+	appendArgs        StringSlice
+	setPathArgs       StringSlice
+	requirePaths      StringSlice
+	makeRelative      StringSlice
+	makeAbsolute      StringSlice
+	defaultsArgs      StringSlice
+	blankLineBefore   StringSlice
 )
 
 func init() {
-	flag.Var(&setArgs, "set", "Set property in format 'key=value' or 'key=value#comment' (can be used multiple times)")
+	flag.Var(&setArgs, "set", "Set property in format 'key=value' or 'key=value#comment', '\\#' is a '#' in the value (can be used multiple times)")
+	flag.Var(&setPathArgs, "set-path", "Set property to a path in format 'key=path', escaped like Android Studio does, e.g. 'sdk.dir=C:\\Android\\Sdk' (can be used multiple times)")
+	flag.Var(&requirePaths, "require-path", "Fail without saving if this key is missing or the path it holds doesn't exist, e.g. sdk.dir (can be used multiple times)")
+	flag.Var(&makeRelative, "make-relative", "Rewrite the path of this key relative to the directory of the input file, e.g. before committing it (can be used multiple times)")
+	flag.Var(&makeAbsolute, "make-absolute", "Rewrite the path of this key as an absolute path, resolving it against the directory of the input file (can be used multiple times)")
 	flag.Var(&rmArgs, "rm", "Remove property by key (can be used multiple times)")
+	flag.Var(&renameArgs, "rename", "Rename a key in format 'old=new', keeping its value, comment and position; fails if new is set, unless -rename-overwrite (can be used multiple times)")
+	flag.Var(&setCommentArgs, "set-comment", "Set the inline comment of an existing key in format 'key=comment', keeping its value (can be used multiple times)")
+	flag.Var(&rmCommentArgs, "rm-comment", "Remove the inline comment of this key, keeping its value (can be used multiple times)")
+	flag.Var(&disableArgs, "disable", "Comment out the property of this key instead of removing it, '#key=value  # disabled by gpm' (can be used multiple times)")
+	flag.Var(&enableArgs, "enable", "Uncomment the property of this key commented out by -disable or by hand, like '#key=value' (can be used multiple times)")
+	flag.Var(&defaultsArgs, "defaults", "Add the keys missing from the input from this property file, a path or an http(s) URL cached on disk (can be used multiple times, the first file with a key wins)")
+	flag.Var(&blankLineBefore, "blank-line-before", "Separate this key from the lines above it with a blank line, above its comment lines, unless there is one (can be used multiple times)")
+	flag.Var(&appendArgs, "append", "Append the line 'key=value' or 'key=value#comment' as is to the end of the file without parsing or rewriting it, a key already set getting a second line that overrides it (can be used multiple times)")
 	flag.Usage = func() {
 		fmt.Println("Usage: property-modify [options]")
+		fmt.Println("       property-modify <command> [options]")
 		fmt.Printf("version: %s \n", VERSION)
+		fmt.Println("commands:")
+		for _, c := range commands {
+			fmt.Printf("  %-16s %s\n", c.name, c.summary)
+		}
+		fmt.Println("options:")
 		flag.PrintDefaults()
 	}
 }
 
+// Command is a subcommand selected by the first argument.
+type Command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []Command{
+	{"anonymize", "Copy a property file with its keys, values and comments replaced by placeholders", runAnonymize},
+	{"apply", "Converge a property file to a desired state", runApply},
+	{"assert", "Check that a property file matches a golden file", runAssert},
+	{"bench", "Time parsing, modifying and saving a property file", runBench},
+	{"bundle", "Check and synchronize the locales of a Java resource bundle", runBundle},
+	{"cat", "Concatenate property files into one with source markers", runCat},
+	{"compat", "Report how well property files survive a parse and save round trip", runCompat},
+	{"expand", "Write one property file per combination of a matrix of flavors, ABIs, ...", runExpand},
+	{"history", "List the git commits that changed the value of a key", runHistory},
+	{"lsp", "Run a language server for editors on stdin and stdout", runLSP},
+	{"repl", "Edit a property file interactively", runREPL},
+	{"redact", "Copy a property file with its secret values replaced by a placeholder", runRedact},
+	{"render", "Render property files from a Go template and a JSON or YAML data file", runRender},
+	{"rotate", "Replace the secret values due for rotation according to their @rotate annotation", runRotate},
+	{"split-by-marker", "Split a file written by cat back into its files", runSplitByMarker},
+}
+
+// registerCommand adds a command built only without some build tags,
+// keeping the commands sorted by name.
+func registerCommand(c Command) {
+	i := sort.Search(len(commands), func(i int) bool {
+		return commands[i].name >= c.name
+	})
+	commands = slices.Insert(commands, i, c)
+}
+
+func findCommand(name string) *Command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
 func parseSetArg(arg string) (key, value, comment string, err error) {
 	parts := strings.SplitN(arg, "=", 2)
 	if len(parts) != 2 {
@@ -59,16 +223,33 @@ func parseSetArg(arg string) (key, value, comment string, err error) {
 	key = parts[0]
 	valueAndComment := parts[1]
 
-	if commentIdx := strings.Index(valueAndComment, "#"); commentIdx != -1 {
+	if commentIdx := unescapedIndex(valueAndComment, '#'); commentIdx != -1 {
 		value = valueAndComment[:commentIdx]
 		comment = valueAndComment[commentIdx+1:]
 	} else {
 		value = valueAndComment
 	}
+	// the escape is written back on save, every '#' of the value being
+	// escaped: the comment starts at the first one that isn't
+	value = strings.ReplaceAll(value, `\#`, "#")
 
 	return key, value, comment, nil
 }
 
+// unescapedIndex returns the index of the first c in s without a
+// backslash before it, or -1.
+func unescapedIndex(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
 func buildOperationList() ([]Operation, error) {
 	var operations []Operation
 
@@ -85,7 +266,59 @@ func buildOperationList() ([]Operation, error) {
 		})
 	}
 
-	// keep the remove operations at the end
+	if *setBlock != "" {
+		var block io.Reader = strings.NewReader(*setBlock)
+		if *setBlock == "-" {
+			if *opsStdin {
+				return nil, fmt.Errorf("-set-block - and -ops-stdin can't both read stdin")
+			}
+			block = os.Stdin
+		}
+		blockOps, err := readSetBlock(block)
+		if err != nil {
+			return nil, fmt.Errorf("-set-block: %w", err)
+		}
+		operations = append(operations, blockOps...)
+	}
+
+	for _, arg := range setPathArgs {
+		key, path, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -set-path format: %s (expected key=path)", arg)
+		}
+		operations = append(operations, Operation{
+			Type:  OP_TYPE_SET,
+			Key:   key,
+			Value: gpm.EscapePath(path),
+		})
+	}
+
+	for _, arg := range renameArgs {
+		oldKey, newKey, ok := strings.Cut(arg, "=")
+		if !ok || oldKey == "" || newKey == "" {
+			return nil, fmt.Errorf("invalid -rename format: %s (expected old=new)", arg)
+		}
+		operations = append(operations, Operation{
+			Type:   OP_TYPE_RENAME,
+			Key:    oldKey,
+			NewKey: newKey,
+		})
+	}
+
+	for _, key := range enableArgs {
+		operations = append(operations, Operation{
+			Type: OP_TYPE_ENABLE,
+			Key:  key,
+		})
+	}
+
+	// keep the remove and disable operations at the end
+	for _, key := range disableArgs {
+		operations = append(operations, Operation{
+			Type: OP_TYPE_DISABLE,
+			Key:  key,
+		})
+	}
 	for _, rmArg := range rmArgs {
 		operations = append(operations, Operation{
 			Type: OP_TYPE_RM,
@@ -93,94 +326,652 @@ func buildOperationList() ([]Operation, error) {
 		})
 	}
 
+	if *opsStdin {
+		stdinOps, err := readOperations(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, stdinOps...)
+	}
+
+	for i := range operations {
+		if operations[i].Type == OP_TYPE_SET {
+			operations[i].After, operations[i].Before = *insertAfter, *insertBefore
+		}
+	}
 	return operations, nil
 }
 
+// runLint prints the lint issues of the input file and returns the exit
+// code.
+func runLint(input string, opts gpm.NormalizeOptions, withValidate bool) int {
+	data, err := readInput(input)
+	if err != nil {
+		fmt.Println("Error opening input file:", err)
+		return 2
+	}
+
+	issues, err := gpm.Lint(bytes.NewReader(data), opts)
+	if err != nil {
+		fmt.Println("Error reading input file:", err)
+		return 2
+	}
+	parser := gpm.NewParser()
+	if err := parser.Parse(bytes.NewReader(data)); err != nil {
+		fmt.Println("Error parsing input file:", err)
+		return 2
+	}
+	issues = append(issues, gpm.LintExpired(parser.GetProps(), time.Now())...)
+	if *usageReport != "" {
+		report, err := readUsageReport(*usageReport)
+		if err != nil {
+			fmt.Println("Error reading the usage report:", err)
+			return 2
+		}
+		issues = append(issues, gpm.LintUnread(parser.GetProps(), report)...)
+	}
+	diagnostics := lintDiagnostics(input, issues)
+
+	if withValidate {
+		modifier := gpm.NewModifier(parser.GetProps())
+		modifier.SetConstraints(valueConstraints())
+		diagnostics = append(diagnostics, validationDiagnostics(input, modifier.Validate())...)
+		reg, err := resolveRegistry()
+		if err != nil {
+			return 2
+		}
+		if reg != nil {
+			diagnostics = append(diagnostics, reg.check(input, parser.GetProps())...)
+		}
+	}
+
+	if err := writeDiagnostics(os.Stdout, *diagnosticsFormat, diagnostics); err != nil {
+		fmt.Println("Error writing diagnostics:", err)
+		return 2
+	}
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// setOperation applies a set operation, adding a key that didn't exist
+// next to its After or Before key.
+func setOperation(m *gpm.Modifier, op Operation, existed bool, comment *string) error {
+	if existed || (op.After == "" && op.Before == "") {
+		return m.SetPropertyChecked(op.Key, op.Value, comment)
+	}
+	if err := valueConstraints().Check(op.Key, op.Value); err != nil {
+		return err
+	}
+	prop := gpm.NewProperty(op.Key, op.Value, comment)
+	if op.Before != "" {
+		return m.InsertBefore(op.Before, prop)
+	}
+	return m.InsertAfter(op.After, prop)
+}
+
+// readUsageReport reads the gpm.UsageReport at path, a file or URL.
+func readUsageReport(path string) (gpm.UsageReport, error) {
+	var report gpm.UsageReport
+	data, err := readInput(path)
+	if err != nil {
+		return report, err
+	}
+	err = decodeData(path, data, &report)
+	return report, err
+}
+
+// valueConstraints returns the constraints of the -max-value-length,
+// -ascii-only and -no-control-chars flags.
+func valueConstraints() gpm.Constraints {
+	return gpm.Constraints{
+		MaxLength: *maxValueLength,
+		ASCIIOnly: *asciiOnly,
+		NoControl: *noControlChars,
+	}
+}
+
+// hasRewrites reports whether a flag other than -set and -rm asks to
+// change the file.
+func hasRewrites() bool {
+	return len(defaultsArgs) > 0 || *registry != "" || *mergeFile != "" || len(makeRelative) > 0 || len(makeAbsolute) > 0 || len(setCommentArgs) > 0 || len(rmCommentArgs) > 0 || *normalize || len(blankLineBefore) > 0 || *stripBlankLines || *pruneExpired || *sortRefs || *onlyKeys != "" || *storeTimestamp != TIMESTAMP_KEEP || *lineEnding != LINE_ENDING_KEEP || *asciiOutput != "" || *trailingNewline != TRAILING_NEWLINE_KEEP
+}
+
+// isOffline reports whether -offline or GPM_OFFLINE forbid using the
+// network.
+func isOffline() bool {
+	if *offline {
+		return true
+	}
+	on, _ := strconv.ParseBool(os.Getenv(OFFLINE_ENV))
+	return on
+}
+
+// parserOptions returns the options the input file is parsed with.
+func parserOptions() []gpm.ParserOption {
+	options := bridge.Options{
+		Encoding:       *encoding,
+		Duplicates:     *duplicates,
+		JavaSeparators: *javaSeparators,
+		JavaWhitespace: *javaWhitespace,
+		UnicodeEscapes: *unicodeEscapes,
+		CommentBlocks:  *commentBlocks,
+		QuotedValues:   *quotedValues,
+	}
+	if *commentPrefixes != "" {
+		options.CommentPrefixes = strings.Split(*commentPrefixes, ",")
+	}
+	return options.ParserOptions()
+}
+
+// parseInput parses the property file at path, printing any error.
+func parseInput(path string) (doc *gpm.Document, err error) {
+	data, err := readInput(path)
+	if err != nil {
+		fmt.Println("Error opening input file:", err)
+		return nil, err
+	}
+
+	opts := parserOptions()
+	if *strict {
+		opts = append(opts, gpm.WithErrors(gpm.ERRORS_COLLECT))
+	}
+	if *preserveLayout {
+		doc, err = gpm.ParsePreserving(bytes.NewReader(data), opts...)
+	} else {
+		doc, err = gpm.Parse(bytes.NewReader(data), opts...)
+	}
+	if err != nil {
+		fmt.Println("Error parsing input file:", err)
+		return nil, err
+	}
+	doc.SetBaseDir(filepath.Dir(path))
+	doc.SetSource(path)
+	return
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if c := findCommand(os.Args[1]); c != nil {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
+	keepPatterns, err := parseKeyPatterns(*onlyKeys)
+	if err != nil {
+		fmt.Println("Error parsing arguments:", err)
+		os.Exit(2)
+	}
+	switch *storeTimestamp {
+	case TIMESTAMP_KEEP, TIMESTAMP_DROP, TIMESTAMP_REFRESH:
+	default:
+		fmt.Printf("Error: unknown -store-timestamp %q, expected %s, %s or %s\n", *storeTimestamp, TIMESTAMP_KEEP, TIMESTAMP_DROP, TIMESTAMP_REFRESH)
+		os.Exit(2)
+	}
+	switch *duplicates {
+	case gpm.DUPLICATES_KEEP_ALL, gpm.DUPLICATES_KEEP_FIRST, gpm.DUPLICATES_KEEP_LAST, gpm.DUPLICATES_ERROR:
+	default:
+		fmt.Printf("Error: unknown -duplicates %q, expected %s, %s, %s or %s\n", *duplicates, gpm.DUPLICATES_KEEP_ALL, gpm.DUPLICATES_KEEP_FIRST, gpm.DUPLICATES_KEEP_LAST, gpm.DUPLICATES_ERROR)
+		os.Exit(2)
+	}
+	switch *prefer {
+	case "", PREFER_OURS, PREFER_THEIRS, PREFER_NEWEST:
+	case PREFER_ASK:
+		if *opsStdin || *setBlock == "-" {
+			fmt.Println("Error: -prefer ask reads the answers from stdin, it can't be combined with -ops-stdin or -set-block -")
+			os.Exit(2)
+		}
+	default:
+		fmt.Printf("Error: unknown -prefer %q, expected %s, %s, %s or %s\n", *prefer, PREFER_OURS, PREFER_THEIRS, PREFER_NEWEST, PREFER_ASK)
+		os.Exit(2)
+	}
+	switch *modifiedFrom {
+	case MODIFIED_FILE, MODIFIED_ANNOTATION, MODIFIED_GIT:
+	default:
+		fmt.Printf("Error: unknown -modified-from %q, expected %s, %s or %s\n", *modifiedFrom, MODIFIED_FILE, MODIFIED_ANNOTATION, MODIFIED_GIT)
+		os.Exit(2)
+	}
+	switch *asciiOutput {
+	case "", gpm.ASCII_ESCAPE, gpm.ASCII_TRANSLITERATE:
+	default:
+		fmt.Printf("Error: unknown -ascii-output %q, expected %s or %s\n", *asciiOutput, gpm.ASCII_ESCAPE, gpm.ASCII_TRANSLITERATE)
+		os.Exit(2)
+	}
+	switch *lineEnding {
+	case LINE_ENDING_KEEP, LINE_ENDING_LF, LINE_ENDING_CRLF:
+	default:
+		fmt.Printf("Error: unknown -line-ending %q, expected %s, %s or %s\n", *lineEnding, LINE_ENDING_KEEP, LINE_ENDING_LF, LINE_ENDING_CRLF)
+		os.Exit(2)
+	}
+	switch *trailingNewline {
+	case TRAILING_NEWLINE_KEEP, TRAILING_NEWLINE_ADD, TRAILING_NEWLINE_REMOVE:
+	default:
+		fmt.Printf("Error: unknown -trailing-newline %q, expected %s, %s or %s\n", *trailingNewline, TRAILING_NEWLINE_KEEP, TRAILING_NEWLINE_ADD, TRAILING_NEWLINE_REMOVE)
+		os.Exit(2)
+	}
+	if *failOnChange && *failOnNoChange {
+		fmt.Println("Error: -fail-on-change and -fail-on-no-change are exclusive")
+		os.Exit(2)
+	}
+	if *insertAfter != "" && *insertBefore != "" {
+		fmt.Println("Error: -after and -before are exclusive")
+		os.Exit(2)
+	}
+	if *onlyKeys != "" && (*outputFile == "" || *outputFile == *inputFile) {
+		fmt.Println("Error: -only-keys needs an -output file other than the input")
+		os.Exit(2)
+	}
+
 	if *outputFile == "" {
 		*outputFile = *inputFile
 	}
 
-	operations, err := buildOperationList()
-	if err != nil {
-		fmt.Println("Error parsing arguments:", err)
-		return
+	normalizeOpts := gpm.NormalizeOptions{
+		TabWidth:      *tabWidth,
+		MaxBlankLines: *maxBlankLines,
 	}
 
-	if len(operations) == 0 {
-		fmt.Println("No operations specified. Use -set or -rm flags to modify properties.")
+	if *lint {
+		os.Exit(runLint(*inputFile, normalizeOpts, *validate))
+	}
+
+	if *dumpAST != "" {
+		if *dumpAST != "json" {
+			fmt.Printf("Error: unknown -dump-ast format %q, expected json\n", *dumpAST)
+			os.Exit(2)
+		}
+		doc, err := parseInput(*inputFile)
+		if err != nil {
+			os.Exit(2)
+		}
+		if err := wire.Write(os.Stdout, wire.NewAST(doc.AST())); err != nil {
+			fmt.Println("Error dumping AST:", err)
+			os.Exit(1)
+		}
 		return
 	}
 
-	parser, err := func() (parser *gpm.Parser, err error) {
-		once := sync.Once{}
-		file, err := os.Open(*inputFile)
+	if *list {
+		doc, err := parseInput(*inputFile)
 		if err != nil {
-			fmt.Println("Error opening input file:", err)
-			return nil, err
+			os.Exit(2)
+		}
+		if _, err := resolveDefaults(doc.Modifier); err != nil {
+			os.Exit(1)
 		}
-		close := func() {
-			file.Close()
+		list := wire.NewList(doc.Props())
+		if *includeDisabled {
+			list = wire.NewListIncludingDisabled(doc.Props())
 		}
-		defer once.Do(close)
+		if *provenance {
+			list.WithProvenance(doc.Modifier)
+		}
+		if err := wire.Write(os.Stdout, list); err != nil {
+			fmt.Println("Error listing properties:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-		parser = gpm.NewParser()
-		err = parser.Parse(file)
+	if *genGo != "" {
+		doc, err := parseInput(*inputFile)
 		if err != nil {
-			fmt.Println("Error parsing input file:", err)
-			return nil, err
+			os.Exit(2)
+		}
+		if err := gpm.GenerateGo(os.Stdout, *genGo, doc.Props()); err != nil {
+			fmt.Println("Error generating Go source:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(appendArgs) > 0 {
+		if len(setArgs) > 0 || *setBlock != "" || len(rmArgs) > 0 || len(renameArgs) > 0 || len(disableArgs) > 0 || len(enableArgs) > 0 || *opsStdin || hasRewrites() {
+			fmt.Println("Error: -append can't be combined with other changes")
+			os.Exit(2)
+		}
+		if err := appendProperties(*inputFile, *outputFile, appendArgs); err != nil {
+			fmt.Println("Error appending properties:", err)
+			os.Exit(1)
 		}
-		once.Do(close)
 		return
-	}()
+	}
+
+	operations, err := buildOperationList()
 	if err != nil {
+		fmt.Println("Error parsing arguments:", err)
+		os.Exit(2)
+	}
+
+	if len(operations) == 0 && !hasRewrites() && !*validate && len(requirePaths) == 0 {
+		fmt.Println("No operations specified. Use -set or -rm flags to modify properties.")
 		return
 	}
 
-	modifier := gpm.NewModifier(parser.GetProps())
-	modifier.Prepare()
+	doc, err := parseInput(*inputFile)
+	if err != nil {
+		os.Exit(2)
+	}
 
-	for _, op := range operations {
-		switch op.Type {
-		case OP_TYPE_SET:
-			var comment *string
-			if op.Comment != "" {
-				comment = &op.Comment
+	modifier := doc.Modifier
+	modifier.SetConstraints(valueConstraints())
+	modifier.Snapshot(INPUT_SNAPSHOT)
+	inputFingerprint := modifier.Fingerprint()
+
+	var explanations []Explanation
+	touched := make(map[string]bool)
+	added, err := resolveDefaults(modifier)
+	if err != nil {
+		os.Exit(1)
+	}
+	reg, err := resolveRegistry()
+	if err != nil {
+		os.Exit(1)
+	}
+	if reg != nil {
+		completed, err := reg.complete(modifier)
+		if err != nil {
+			fmt.Println("Error completing from the registry:", err)
+			os.Exit(1)
+		}
+		added = append(added, completed...)
+	}
+	for _, key := range added {
+		touched[key] = true
+	}
+	merged, err := mergeOperations(doc)
+	if err != nil {
+		os.Exit(1)
+	}
+	operations = append(merged, operations...)
+	// follow is the last key added after every After key, which the next
+	// one follows, so that the keys keep the order of the operations
+	follow := make(map[string]string)
+	for _, selected := range operations {
+		expanded, err := selected.expand(modifier)
+		if err != nil {
+			fmt.Println("Error parsing arguments:", err)
+			os.Exit(2)
+		}
+		if len(expanded) == 0 && *explainOps {
+			explanations = append(explanations, explain(selected, false, false))
+		}
+		for _, op := range expanded {
+			touched[op.Key] = true
+			if op.NewKey != "" {
+				touched[op.NewKey] = true
+			}
+			_, existed := modifier.Get(op.Key)
+			var before string
+			if *explainOps {
+				before = modifier.Fingerprint()
+			}
+			switch op.Type {
+			case OP_TYPE_SET:
+				var comment *string
+				if op.Comment != "" {
+					comment = &op.Comment
+				}
+				after := op.After
+				if last, ok := follow[after]; ok {
+					op.After = last
+				}
+				if err := setOperation(modifier, op, existed, comment); err != nil {
+					fmt.Println("Error setting property:", err)
+					os.Exit(1)
+				}
+				if !existed && after != "" {
+					follow[after] = op.Key
+				}
+			case OP_TYPE_RM:
+				modifier.RemoveProperty(op.Key)
+			case OP_TYPE_DISABLE:
+				modifier.DisableProperty(op.Key)
+			case OP_TYPE_ENABLE:
+				modifier.EnableProperty(op.Key)
+			case OP_TYPE_RENAME:
+				if err := modifier.RenameKey(op.Key, op.NewKey, *renameOverwrite); err != nil {
+					fmt.Println("Error renaming property:", err)
+					os.Exit(1)
+				}
+			}
+			if *explainOps {
+				explanations = append(explanations, explain(op, existed, modifier.Fingerprint() != before))
 			}
-			modifier.SetProperty(op.Key, op.Value, comment)
-		case OP_TYPE_RM:
-			modifier.RemoveProperty(op.Key)
+		}
+	}
+	for _, arg := range setCommentArgs {
+		key, comment, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			fmt.Printf("Error: invalid -set-comment format: %s (expected key=comment)\n", arg)
+			os.Exit(2)
+		}
+		keys, err := expandKeys(modifier, key)
+		if err != nil {
+			fmt.Println("Error parsing arguments:", err)
+			os.Exit(2)
+		}
+		for _, key := range keys {
+			touched[key] = true
+			if err := modifier.SetComment(key, comment); err != nil {
+				fmt.Println("Error setting comment:", err)
+				os.Exit(1)
+			}
+		}
+	}
+	for _, arg := range rmCommentArgs {
+		keys, err := expandKeys(modifier, arg)
+		if err != nil {
+			fmt.Println("Error parsing arguments:", err)
+			os.Exit(2)
+		}
+		for _, key := range keys {
+			touched[key] = true
+			modifier.RemoveComment(key)
+		}
+	}
+	for _, key := range makeRelative {
+		touched[key] = true
+		if err := modifier.MakeRelative(key); err != nil {
+			fmt.Println("Error rewriting path:", err)
+			os.Exit(1)
+		}
+	}
+	for _, key := range makeAbsolute {
+		touched[key] = true
+		if err := modifier.MakeAbsolute(key); err != nil {
+			fmt.Println("Error rewriting path:", err)
+			os.Exit(1)
+		}
+	}
+	if *explainOps {
+		writeExplanations(os.Stdout, explanations)
+	}
+
+	if *pruneExpired {
+		for _, key := range modifier.PruneExpired(time.Now()) {
+			fmt.Println("Pruned expired property:", key)
+		}
+	}
+
+	switch *storeTimestamp {
+	case TIMESTAMP_DROP:
+		modifier.RemoveStoreTimestamp()
+	case TIMESTAMP_REFRESH:
+		modifier.RefreshStoreTimestamp(time.Now())
+	}
+
+	if *normalize {
+		modifier.Normalize(normalizeOpts)
+	}
+	for _, key := range blankLineBefore {
+		if err := modifier.InsertBlankLineBefore(key); err != nil {
+			fmt.Println("Error inserting blank line:", err)
+			os.Exit(1)
+		}
+	}
+	if *stripBlankLines {
+		modifier.CollapseBlankLines(0)
+	}
+
+	switch *trailingNewline {
+	case TRAILING_NEWLINE_ADD:
+		modifier.SetFinalNewline(true)
+	case TRAILING_NEWLINE_REMOVE:
+		modifier.SetFinalNewline(false)
+	}
+
+	if *asciiOutput != "" {
+		for _, t := range modifier.ToASCII(*asciiOutput) {
+			fmt.Printf("Made line %d ASCII: %s -> %s\n", t.Line, t.Before, t.After)
 		}
 	}
 
-	outTmpFile := *outputFile + ".tmp"
+	if *sortRefs {
+		if err := modifier.SortByReferences(); err != nil {
+			fmt.Println("Error sorting by references:", err)
+			os.Exit(1)
+		}
+	}
 
-	err = func() (err error) {
-		file, err := os.Create(outTmpFile)
+	if *validate {
+		diagnostics := validationDiagnostics(*inputFile, modifier.Validate())
+		if reg != nil {
+			diagnostics = append(diagnostics, reg.check(*inputFile, doc.Props())...)
+		}
+		if len(diagnostics) > 0 {
+			if err := writeDiagnostics(os.Stdout, *diagnosticsFormat, diagnostics); err != nil {
+				fmt.Println("Error writing diagnostics:", err)
+			}
+			os.Exit(1)
+		}
+	}
+
+	missingPath := false
+	for _, key := range requirePaths {
+		if err := modifier.CheckPath(key); err != nil {
+			fmt.Println("Error checking path:", err)
+			missingPath = true
+		}
+	}
+	if missingPath {
+		os.Exit(1)
+	}
+	if len(operations) == 0 && !hasRewrites() {
+		return
+	}
+
+	changed := modifier.Fingerprint() != inputFingerprint
+
+	if *diff {
+		changes, err := modifier.DiffSnapshot(INPUT_SNAPSHOT)
 		if err != nil {
-			fmt.Println("Error creating output file:", err)
-			return err
+			fmt.Println("Error comparing with the input file:", err)
+			os.Exit(1)
+		}
+		if err := wire.Write(os.Stdout, wire.NewDiff(changes)); err != nil {
+			fmt.Println("Error writing changes:", err)
+			os.Exit(1)
 		}
-		defer file.Close()
+		os.Exit(changeExitCode(*inputFile, changed))
+	}
 
-		err = modifier.Save(file)
+	saveOpts := []gpm.SaveOption{gpm.WithWrap(*wrapColumn)}
+	if *unicodeEscapes {
+		saveOpts = append(saveOpts, gpm.WithUnicodeEscapes())
+	}
+	switch *lineEnding {
+	case LINE_ENDING_LF:
+		saveOpts = append(saveOpts, gpm.WithLineEnding(gpm.LINE_ENDING_LF))
+	case LINE_ENDING_CRLF:
+		saveOpts = append(saveOpts, gpm.WithLineEnding(gpm.LINE_ENDING_CRLF))
+	}
+	save := func(w io.Writer) error {
+		return modifier.Save(w, saveOpts...)
+	}
+	if len(keepPatterns) > 0 {
+		save = func(w io.Writer) error {
+			return modifier.SaveFiltered(w, func(key string) bool {
+				return keepPatterns.Match(key)
+			}, saveOpts...)
+		}
+	}
+	if *minimalDiff {
+		input, err := readInput(*inputFile)
 		if err != nil {
-			fmt.Println("Error saving output file:", err)
-			return err
+			fmt.Println("Error reading input file:", err)
+			os.Exit(1)
 		}
+		save = minimalSave(input, touched, save)
+	}
+	if err := writeOutput(*outputFile, save); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(changeExitCode(*inputFile, changed))
+}
 
-		return nil
-	}()
-	if err != nil {
-		return
+// changeExitCode returns 1 if -fail-on-change is set and the operations
+// changed the file, or -fail-on-no-change is set and they didn't.
+func changeExitCode(path string, changed bool) int {
+	switch {
+	case *failOnChange && changed:
+		fmt.Fprintln(os.Stderr, "Changed:", path)
+		return 1
+	case *failOnNoChange && !changed:
+		fmt.Fprintln(os.Stderr, "Unchanged:", path)
+		return 1
 	}
+	return 0
+}
+
+// saveOutput atomically replaces the file at path with the content of
+// modifier, printing any error.
+func saveOutput(path string, modifier *gpm.Modifier, opts ...gpm.SaveOption) error {
+	return writeOutput(path, func(w io.Writer) error {
+		return modifier.Save(w, opts...)
+	})
+}
 
-	// replace the original file with the new file
-	err = os.Rename(outTmpFile, *outputFile)
+// writeOutput atomically replaces the file at path, or the object at
+// its URL, with what save writes, printing any error.
+func writeOutput(path string, save func(w io.Writer) error) error {
+	var out bytes.Buffer
+	if err := save(&out); err != nil {
+		fmt.Println("Error saving output file:", err)
+		return err
+	}
+	store, name, err := storageFor(path)
 	if err != nil {
-		fmt.Println("Error renaming output file:", err)
-		return
+		fmt.Println("Error writing output file:", err)
+		return err
+	}
+	perm := fs.FileMode(0o644)
+	if info, err := store.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	err = store.Write(name, out.Bytes(), perm)
+	if err != nil && isReadOnly(err) && *outputDir != "" && !isRemote(path) {
+		fallback := filepath.Join(*outputDir, filepath.Base(path))
+		if err = store.Write(fallback, out.Bytes(), perm); err == nil {
+			fmt.Printf("Wrote %s instead of %s, which is read-only\n", fallback, path)
+			return nil
+		}
+	}
+	if err != nil {
+		fmt.Println("Error writing output file:", err)
+		if isReadOnly(err) && *outputDir == "" {
+			fmt.Printf("%s can't be written, write the output elsewhere with -output <file> or -output-dir <dir>\n", path)
+		}
+		return err
 	}
+	return nil
+}
+
+// isReadOnly reports whether err comes from a read-only file system or
+// missing write permissions.
+func isReadOnly(err error) bool {
+	return errors.Is(err, syscall.EROFS) || errors.Is(err, fs.ErrPermission)
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when GPM_TEST_MAIN is set, for
+// runMain to run the command in a child process.
+func TestMain(m *testing.M) {
+	if os.Getenv("GPM_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the command with args and returns its output and exit code.
+func runMain(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GPM_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "local.properties")
+	if err := os.WriteFile(input, []byte("a=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.properties")
+	if err := os.WriteFile(invalid, []byte("a=1\n\\u12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"set", []string{"-input", input, "-set", "b=2"}, 0},
+		{"unknown flag", []string{"-no-such-flag"}, 2},
+		{"invalid set", []string{"-input", input, "-set", "b"}, 2},
+		{"missing input", []string{"-input", filepath.Join(dir, "missing.properties"), "-set", "b=2"}, 2},
+		{"invalid input", []string{"-input", invalid, "-strict", "-set", "b=2"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code := runMain(t, tt.args...)
+			if code != tt.want {
+				t.Errorf("exit code %d, want %d, output:\n%s", code, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestCommentPatterns(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "local.properties")
+	if err := os.WriteFile(input, []byte("a.x=1 # old\na.y=2\nb=3 # keep\nc=4 # drop\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, code := runMain(t, "-input", input, "-set-comment", "glob:a.*=note", "-rm-comment", "re:[c]")
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	got, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.x=1 # note\na.y=2 # note\nb=3 # keep\nc=4\n"
+	if string(got) != want {
+		t.Errorf("saved %q, want %q", got, want)
+	}
+}
+
+func TestAppendLiteralLines(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "local.properties")
+	if err := os.WriteFile(input, []byte("a=1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, code := runMain(t, "-input", input, "-append", "c=3#hi", "-append", `d=\#FF`, "-append", "a=2")
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	got, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a=1\nc=3#hi\nd=\\#FF\na=2\n"
+	if string(got) != want {
+		t.Errorf("saved %q, want %q", got, want)
+	}
+}
+
+func TestSetAfterKeepsOrder(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "local.properties")
+	if err := os.WriteFile(input, []byte("a=1\nb=2\nz=9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, code := runMain(t, "-input", input, "-after", "a", "-set", "c=3", "-set", "b=20", "-set", "d=4")
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	got, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a=1\nc=3\nd=4\nb=20\nz=9\n"
+	if string(got) != want {
+		t.Errorf("saved %q, want %q", got, want)
+	}
+}
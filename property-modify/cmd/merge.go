@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"gpm"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// What -prefer keeps when the input and the -merge file set a key to
+// different values.
+const (
+	PREFER_OURS   = "ours"
+	PREFER_THEIRS = "theirs"
+	PREFER_NEWEST = "newest"
+	PREFER_ASK    = "ask"
+)
+
+// Where -prefer newest reads when the values were last changed.
+const (
+	MODIFIED_FILE       = "file"
+	MODIFIED_ANNOTATION = "annotation"
+	MODIFIED_GIT        = "git"
+)
+
+// mergeSide is the value a file sets a key to.
+type mergeSide struct {
+	value   string
+	comment string
+	from    gpm.Provenance
+}
+
+func (s mergeSide) String() string {
+	if s.comment == "" {
+		return fmt.Sprintf("%q (%s)", s.value, s.from)
+	}
+	return fmt.Sprintf("%q # %s (%s)", s.value, s.comment, s.from)
+}
+
+// sideOf returns the value doc sets key to, with the comment of its last
+// line.
+func sideOf(doc *gpm.Document, key string) mergeSide {
+	side := mergeSide{}
+	side.value, _ = doc.Get(key)
+	side.from, _ = doc.Explain(key)
+	for _, p := range doc.Props() {
+		if p.Key() == key {
+			side.comment = p.Comment()
+		}
+	}
+	return side
+}
+
+// modTime returns when the file at source was last modified, the zero
+// time for a URL or a file that can't be read.
+func modTime(source string) time.Time {
+	if isRemote(source) {
+		return time.Time{}
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// modifiedTimes returns when the values of the keys of doc, read from
+// source, were last changed, as -modified-from says. A key without a time
+// has the zero time.
+func modifiedTimes(doc *gpm.Document, source string) (map[string]time.Time, error) {
+	switch *modifiedFrom {
+	case MODIFIED_GIT:
+		if isRemote(source) {
+			return nil, fmt.Errorf("can't read the git history of %s", source)
+		}
+		return gpm.GitModified(source)
+	case MODIFIED_ANNOTATION:
+		times := make(map[string]time.Time)
+		for _, p := range doc.Props() {
+			if p.Key() == "" {
+				continue
+			}
+			t, ok, err := p.Modified()
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", source, p.LineNum(), err)
+			}
+			if ok {
+				times[p.Key()] = t
+			}
+		}
+		return times, nil
+	}
+	times := make(map[string]time.Time)
+	t := modTime(source)
+	for _, key := range doc.Keys() {
+		times[key] = t
+	}
+	return times, nil
+}
+
+// formatTime formats a time of modifiedTimes for the merge report.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// mergeOperations returns the set operations merging the -merge file into
+// doc: the keys it adds, and its values of the conflicting keys -prefer
+// picks. It prints any error.
+func mergeOperations(doc *gpm.Document) ([]Operation, error) {
+	if *mergeFile == "" {
+		return nil, nil
+	}
+	cache, err := NewRemoteCache(*cacheDir, *defaultsTTL, isOffline())
+	if err != nil {
+		fmt.Println("Error opening the defaults cache:", err)
+		return nil, err
+	}
+	theirs, err := loadDefaults(*mergeFile, cache)
+	if err != nil {
+		fmt.Println("Error reading the merged file:", err)
+		return nil, err
+	}
+
+	var operations []Operation
+	var conflicts []string
+	for _, key := range theirs.Keys() {
+		value, _ := theirs.Get(key)
+		ours, ok := doc.Get(key)
+		if ok && ours == value {
+			continue
+		}
+		if !ok {
+			operations = append(operations, Operation{Type: OP_TYPE_SET, Key: key, Value: value})
+			continue
+		}
+		conflicts = append(conflicts, key)
+	}
+	if len(conflicts) > 0 && *prefer == "" {
+		fmt.Printf("Error: %s and %s set %s to different values, choose with -prefer\n", doc.Source(), *mergeFile, strings.Join(conflicts, ", "))
+		return nil, fmt.Errorf("merge conflicts")
+	}
+
+	var ourTimes, theirTimes map[string]time.Time
+	if len(conflicts) > 0 && *prefer == PREFER_NEWEST {
+		if ourTimes, err = modifiedTimes(doc, doc.Source()); err == nil {
+			theirTimes, err = modifiedTimes(theirs, *mergeFile)
+		}
+		if err != nil {
+			fmt.Println("Error reading modification times:", err)
+			return nil, err
+		}
+	}
+	answers := bufio.NewReader(os.Stdin)
+	for _, key := range conflicts {
+		our, their := sideOf(doc, key), sideOf(theirs, key)
+		resolved := their
+		switch *prefer {
+		case PREFER_OURS:
+			fmt.Printf("Merged %s: kept ours\n", key)
+			continue
+		case PREFER_THEIRS:
+			fmt.Printf("Merged %s: took theirs\n", key)
+		case PREFER_NEWEST:
+			// a tie takes theirs
+			ourTime, theirTime := ourTimes[key], theirTimes[key]
+			if theirTime.Before(ourTime) {
+				fmt.Printf("Merged %s: kept ours, changed %s, theirs %s\n", key, formatTime(ourTime), formatTime(theirTime))
+				continue
+			}
+			fmt.Printf("Merged %s: took theirs, changed %s, ours %s\n", key, formatTime(theirTime), formatTime(ourTime))
+		case PREFER_ASK:
+			value, ok, err := askConflict(os.Stdout, answers, key, our, their)
+			if err != nil {
+				fmt.Println("Error reading the answer:", err)
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			resolved = mergeSide{value: value}
+			if value == their.value {
+				resolved = their
+			}
+			if value == our.value {
+				continue
+			}
+		}
+		operations = append(operations, Operation{
+			Type:    OP_TYPE_SET,
+			Key:     key,
+			Value:   resolved.value,
+			Comment: resolved.comment,
+		})
+	}
+	return operations, nil
+}
+
+// askConflict shows both values of key and reads which to keep: o for
+// ours, t for theirs or e to type another value. It returns the value to
+// set, and false to keep ours.
+func askConflict(w io.Writer, answers *bufio.Reader, key string, ours, theirs mergeSide) (string, bool, error) {
+	fmt.Fprintf(w, "Conflict on %s:\n  ours:   %s\n  theirs: %s\n", key, ours, theirs)
+	for {
+		fmt.Fprint(w, "Keep [o]urs, [t]heirs or [e]dit? ")
+		answer, err := answers.ReadString('\n')
+		if err != nil {
+			return "", false, err
+		}
+		switch strings.TrimSpace(answer) {
+		case "o":
+			return "", false, nil
+		case "t":
+			return theirs.value, true, nil
+		case "e":
+			fmt.Fprint(w, "Value: ")
+			value, err := answers.ReadString('\n')
+			if err != nil {
+				return "", false, err
+			}
+			return strings.TrimRight(value, "\r\n"), true, nil
+		}
+	}
+}
@@ -0,0 +1,178 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// requestInfo is shared by the middlewares of a request so the outer ones
+// learn what the inner ones found out.
+type requestInfo struct {
+	token *Token
+}
+
+type requestInfoKey struct{}
+
+func infoOf(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// clientID identifies the client of a request for rate limiting: its token
+// name or its IP address.
+func clientID(r *http.Request) string {
+	if info := infoOf(r); info != nil && info.token != nil {
+		return "token:" + info.token.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// MAX_BUCKETS is the number of clients the rate limiter tracks before it
+// forgets idle ones.
+const MAX_BUCKETS = 10000
+
+// RateLimiter is a token bucket per client.
+type RateLimiter struct {
+	rate  float64 // requests per second
+	burst float64
+	mu    sync.Mutex
+	// buckets are the tokens left per client and when they were counted
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   math.Max(1, float64(burst)),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of client. If it is empty it
+// returns how long to wait for the next one.
+func (l *RateLimiter) Allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buckets) > MAX_BUCKETS {
+		l.prune(now)
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, at: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.at).Seconds()*l.rate)
+	b.at = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune forgets the clients whose bucket refilled, they start over with a
+// full one anyway.
+func (l *RateLimiter) prune(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.at).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	if s.limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := s.limiter.Allow(clientID(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Client   string    `json:"client"`
+	Actor    string    `json:"actor"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Duration string    `json:"duration"`
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// audit writes an AuditEntry per request to the audit log, if there is
+// one. It also sets up the requestInfo of the request.
+func (s *Server) audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		if s.auditLog == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		entry := AuditEntry{
+			Time:     start,
+			Client:   clientID(r),
+			Actor:    actor(r),
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Status:   rec.status,
+			Duration: time.Since(start).String(),
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		s.auditMu.Lock()
+		defer s.auditMu.Unlock()
+		if _, err := s.auditLog.Write(append(line, '\n')); err != nil {
+			fmt.Println("Error writing audit log:", err)
+		}
+	})
+}
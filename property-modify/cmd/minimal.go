@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gpm"
+	"io"
+	"strings"
+)
+
+// minimalSave wraps save so that it fails instead of writing anything if
+// the result differs from input on a line whose key is not in touched.
+func minimalSave(input []byte, touched map[string]bool, save func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		var buf bytes.Buffer
+		if err := save(&buf); err != nil {
+			return err
+		}
+		if err := checkMinimalDiff(input, buf.Bytes(), touched); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+}
+
+// checkMinimalDiff checks that, without the lines of the touched keys,
+// before and after are byte for byte the same.
+func checkMinimalDiff(before, after []byte, touched map[string]bool) error {
+	b, err := untouchedLines(before, touched)
+	if err != nil {
+		return err
+	}
+	a, err := untouchedLines(after, touched)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(b) || i < len(a); i++ {
+		switch {
+		case i >= len(a):
+			return fmt.Errorf("minimal diff not possible: line %q would be removed", b[i])
+		case i >= len(b):
+			return fmt.Errorf("minimal diff not possible: line %q would be added", a[i])
+		case a[i] != b[i]:
+			return fmt.Errorf("minimal diff not possible: line %q would become %q", b[i], a[i])
+		}
+	}
+	return nil
+}
+
+// untouchedLines returns the lines of data that don't belong to a touched
+// key.
+func untouchedLines(data []byte, touched map[string]bool) ([]string, error) {
+	parser := gpm.NewParser()
+	if err := parser.Parse(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	skip := make(map[int]bool)
+	for _, p := range parser.GetProps() {
+		if touched[p.Key()] {
+			for i := 0; i < p.Lines(); i++ {
+				skip[p.LineNum()+i] = true
+			}
+		}
+	}
+	var lines []string
+	for i, line := range strings.Split(string(data), "\n") {
+		if !skip[i+1] {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"gpm"
+	"io"
+	"strings"
+)
+
+// readOperations reads one operation per line:
+//
+//	set key=value#comment
+//	rm key
+//	rename old new
+//	disable key
+//	enable key
+//
+// Blank lines and lines starting with '#' are skipped.
+func readOperations(r io.Reader) ([]Operation, error) {
+	var operations []Operation
+	lineNum := 0
+	buf := bufio.NewScanner(r)
+	for buf.Scan() {
+		lineNum++
+		line := strings.TrimSpace(buf.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		op, err := parseOperation(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		operations = append(operations, op)
+	}
+	if err := buf.Err(); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// readSetBlock reads a block of property lines, like a chunk pasted from
+// a file, as set operations. It is parsed like the input file, the
+// comment of a line becoming the comment of its key, and fails on lines
+// that aren't properties.
+func readSetBlock(r io.Reader) ([]Operation, error) {
+	doc, err := gpm.Parse(r, append(parserOptions(), gpm.WithErrors(gpm.ERRORS_STRICT))...)
+	if err != nil {
+		return nil, err
+	}
+	var operations []Operation
+	for _, p := range doc.Props() {
+		if p.Key() == "" {
+			continue
+		}
+		operations = append(operations, Operation{
+			Type:    OP_TYPE_SET,
+			Key:     p.Key(),
+			Value:   p.Value(),
+			Comment: p.Comment(),
+		})
+	}
+	return operations, nil
+}
+
+func parseOperation(line string) (Operation, error) {
+	typ, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch typ {
+	case OP_TYPE_SET:
+		key, value, comment, err := parseSetArg(rest)
+		if err != nil {
+			return Operation{}, err
+		}
+		return Operation{
+			Type:    OP_TYPE_SET,
+			Key:     key,
+			Value:   value,
+			Comment: comment,
+		}, nil
+	case OP_TYPE_RM, OP_TYPE_DISABLE, OP_TYPE_ENABLE:
+		if rest == "" || strings.ContainsAny(rest, " \t") {
+			return Operation{}, fmt.Errorf("invalid %s format: %s (expected %s key)", typ, line, typ)
+		}
+		return Operation{
+			Type: typ,
+			Key:  rest,
+		}, nil
+	case OP_TYPE_RENAME:
+		keys := strings.Fields(rest)
+		if len(keys) != 2 {
+			return Operation{}, fmt.Errorf("invalid rename format: %s (expected rename old new)", line)
+		}
+		return Operation{
+			Type:   OP_TYPE_RENAME,
+			Key:    keys[0],
+			NewKey: keys[1],
+		}, nil
+	}
+	return Operation{}, fmt.Errorf("unknown operation: %s", typ)
+}
+
+// String formats op the way readOperations reads it.
+func (op Operation) String() string {
+	switch op.Type {
+	case OP_TYPE_SET:
+		if op.Comment != "" {
+			return fmt.Sprintf("set %s=%s#%s", op.Key, op.Value, op.Comment)
+		}
+		return fmt.Sprintf("set %s=%s", op.Key, op.Value)
+	case OP_TYPE_RENAME:
+		return fmt.Sprintf("rename %s %s", op.Key, op.NewKey)
+	}
+	return fmt.Sprintf("%s %s", op.Type, op.Key)
+}
+
+// expand returns the operations on the keys of m selected by the key of
+// op. A glob: or re: pattern only selects existing keys, so a set changes
+// them without creating any, and the new key of a rename is expanded for
+// every key, see gpm.KeyMatcher.Rename. A plain key gives op itself.
+func (op Operation) expand(m *gpm.Modifier) ([]Operation, error) {
+	k, err := gpm.CompileKeyMatcher(op.Key)
+	if err != nil {
+		return nil, err
+	}
+	if k.IsExact() || op.Type == OP_TYPE_ENABLE {
+		// the keys to enable are not in m yet
+		return []Operation{op}, nil
+	}
+	var operations []Operation
+	for _, key := range m.MatchingKeys(k) {
+		o := op
+		o.Key = key
+		if op.Type == OP_TYPE_RENAME {
+			o.NewKey = k.Rename(key, op.NewKey)
+		}
+		operations = append(operations, o)
+	}
+	return operations, nil
+}
+
+// apply applies op to m, or to every key of m selected by a glob: or re:
+// pattern, see expand.
+func (op Operation) apply(m *gpm.Modifier) error {
+	operations, err := op.expand(m)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		return fmt.Errorf("no key matches %q", op.Key)
+	}
+	for _, o := range operations {
+		switch o.Type {
+		case OP_TYPE_SET:
+			var comment *string
+			if o.Comment != "" {
+				comment = &o.Comment
+			}
+			if err := m.SetProperty(o.Key, o.Value, comment); err != nil {
+				return err
+			}
+		case OP_TYPE_RM:
+			if !m.RemoveProperty(o.Key) {
+				return fmt.Errorf("key %q not found", o.Key)
+			}
+		case OP_TYPE_RENAME:
+			if err := m.RenameKey(o.Key, o.NewKey, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandKeys returns the keys of m selected by key, a plain key or a glob:
+// or re: pattern, like expand does for operations.
+func expandKeys(m *gpm.Modifier, key string) ([]string, error) {
+	operations, err := Operation{Key: key}.expand(m)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(operations))
+	for i, op := range operations {
+		keys[i] = op.Key
+	}
+	return keys, nil
+}
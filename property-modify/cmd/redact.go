@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gpm"
+	"regexp"
+)
+
+// RedactRules select the properties whose values are secret, in addition
+// to the ones annotated with @secret:
+//
+//	placeholder: "<redacted>"
+//	keys: ["*.password", "signing.*"]
+//	values: ["^AKIA[0-9A-Z]{16}$", "^ghp_"]
+type RedactRules struct {
+	Placeholder string   `yaml:"placeholder" json:"placeholder"`
+	Keys        []string `yaml:"keys" json:"keys"`     // path.Match patterns or re: regular expressions
+	Values      []string `yaml:"values" json:"values"` // regular expressions
+
+	keys   gpm.KeyMatchers
+	values []*regexp.Regexp
+}
+
+func loadRedactRules(file string) (*RedactRules, error) {
+	rules := RedactRules{Placeholder: "<redacted>"}
+	if file != "" {
+		if err := loadData(file, &rules); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := gpm.CompileKeyGlobs(rules.Keys)
+	if err != nil {
+		return nil, err
+	}
+	rules.keys = keys
+	for _, expr := range rules.Values {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value pattern %q: %w", expr, err)
+		}
+		rules.values = append(rules.values, re)
+	}
+	return &rules, nil
+}
+
+// secret reports whether the rules select p.
+func (r *RedactRules) secret(p *gpm.Property) bool {
+	if r.keys.Match(p.Key()) {
+		return true
+	}
+	for _, re := range r.values {
+		if re.MatchString(p.Value()) {
+			return true
+		}
+	}
+	return false
+}
+
+func runRedact(args []string) int {
+	fs := flag.NewFlagSet("redact", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file to copy")
+	output := fs.String("output", "", "Redacted copy to write")
+	rulesFile := fs.String("rules", "", "YAML or JSON file of the secret keys and values, @secret properties are always redacted")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify redact [options]")
+		fmt.Println("Copy a property file with the secret values replaced by a placeholder, e.g. to attach it to a bug report.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *output == "" || *output == *input {
+		fs.Usage()
+		return 2
+	}
+
+	rules, err := loadRedactRules(*rulesFile)
+	if err != nil {
+		fmt.Println("Error reading redaction rules:", err)
+		return 2
+	}
+	doc, err := parseInput(*input)
+	if err != nil {
+		return 2
+	}
+
+	modifier := doc.Modifier
+	keys := modifier.Redact(rules.secret, rules.Placeholder)
+	if len(keys) > 0 {
+		modifier.InsertComment(1, fmt.Sprintf("Redacted copy of %s: the values of %d keys were replaced by %s", *input, len(keys), rules.Placeholder))
+		modifier.InsertBlankLine(2)
+	}
+	if err := saveOutput(*output, modifier); err != nil {
+		return 1
+	}
+	fmt.Printf("Redacted %d keys\n", len(keys))
+	return 0
+}
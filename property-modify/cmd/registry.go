@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"gpm"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const RULE_REGISTRY = "registry"
+
+// Registry lists the keys an organization approves, shared by its
+// repositories through -registry:
+//
+//	keys:
+//	  - key: app.version
+//	    owner: release-team
+//	    pattern: '[0-9]+\.[0-9]+\.[0-9]+'
+//	    required: true
+//	  - key: app.channel
+//	    owner: release-team
+//	    values: [stable, beta]
+//	    default: stable
+//	  - key: "glob:signing.*"
+//	    owner: security
+//	allowUnknown: false
+type Registry struct {
+	Keys []RegistryKey `yaml:"keys" json:"keys"`
+	// AllowUnknown accepts the keys no entry matches
+	AllowUnknown bool `yaml:"allowUnknown" json:"allowUnknown"`
+
+	source string
+}
+
+// RegistryKey is an approved key, or the keys matching a glob: or re:
+// pattern.
+type RegistryKey struct {
+	Key         string   `yaml:"key" json:"key"`
+	Owner       string   `yaml:"owner" json:"owner"`
+	Description string   `yaml:"description" json:"description"`
+	Values      []string `yaml:"values" json:"values"`   // the allowed values, any if empty
+	Pattern     string   `yaml:"pattern" json:"pattern"` // regular expression the whole value must match
+	Required    bool     `yaml:"required" json:"required"`
+	// Default is the value of the key -registry adds when it is missing,
+	// for a key without pattern
+	Default *string `yaml:"default" json:"default"`
+
+	matcher *gpm.KeyMatcher
+	pattern *regexp.Regexp
+}
+
+// loadRegistry reads the registry at source, a path or an http(s) URL
+// fetched through cache.
+func loadRegistry(source string, cache *RemoteCache) (*Registry, error) {
+	registry := Registry{source: source}
+	if isRemote(source) {
+		data, err := cache.Fetch(source)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeData(source, data, &registry); err != nil {
+			return nil, err
+		}
+	} else if err := loadData(source, &registry); err != nil {
+		return nil, err
+	}
+	for i := range registry.Keys {
+		k := &registry.Keys[i]
+		matcher, err := gpm.CompileKeyMatcher(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		k.matcher = matcher
+		if k.Pattern != "" {
+			re, err := regexp.Compile("^(?:" + k.Pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern: %w", k.Key, err)
+			}
+			k.pattern = re
+		}
+	}
+	return &registry, nil
+}
+
+// resolveRegistry reads the -registry file, printing any error. It
+// returns nil without one.
+func resolveRegistry() (*Registry, error) {
+	if *registry == "" {
+		return nil, nil
+	}
+	cache, err := NewRemoteCache(*cacheDir, *defaultsTTL, isOffline())
+	if err != nil {
+		fmt.Println("Error opening the defaults cache:", err)
+		return nil, err
+	}
+	r, err := loadRegistry(*registry, cache)
+	if err != nil {
+		fmt.Println("Error reading the registry:", err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// entry returns the first entry matching key, nil if there is none.
+func (r *Registry) entry(key string) *RegistryKey {
+	for i := range r.Keys {
+		if r.Keys[i].matcher.Match(key) {
+			return &r.Keys[i]
+		}
+	}
+	return nil
+}
+
+// complete adds the keys of the registry missing from m that have a
+// default value, and returns them.
+func (r *Registry) complete(m *gpm.Modifier) ([]string, error) {
+	var added []string
+	for _, k := range r.Keys {
+		if k.Default == nil || !k.matcher.IsExact() {
+			continue
+		}
+		if _, ok := m.Get(k.Key); ok {
+			continue
+		}
+		if err := m.SetPropertyFrom(k.Key, *k.Default, gpm.Provenance{File: r.source}); err != nil {
+			return nil, err
+		}
+		added = append(added, k.Key)
+	}
+	return added, nil
+}
+
+// check returns the diagnostics of the properties of file the registry
+// doesn't approve: unknown keys, values it doesn't allow and missing
+// required keys.
+func (r *Registry) check(file string, props []gpm.Property) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(line int, format string, args ...any) {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:    file,
+			Line:    line,
+			Rule:    RULE_REGISTRY,
+			Level:   LEVEL_ERROR,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	present := make(map[string]bool)
+	for _, p := range props {
+		if p.Key() == "" {
+			continue
+		}
+		present[p.Key()] = true
+		k := r.entry(p.Key())
+		switch {
+		case k == nil:
+			if !r.AllowUnknown {
+				report(p.LineNum(), "%s: not in the registry %s", p.Key(), r.source)
+			}
+		case len(k.Values) > 0 && !slices.Contains(k.Values, p.Value()):
+			report(p.LineNum(), "%s: %q is not one of %s%s", p.Key(), p.Value(), strings.Join(k.Values, ", "), k.owner())
+		case k.pattern != nil && !k.pattern.MatchString(p.Value()):
+			report(p.LineNum(), "%s: %q does not match %s%s", p.Key(), p.Value(), k.Pattern, k.owner())
+		}
+	}
+	for _, k := range r.Keys {
+		if k.Required && k.matcher.IsExact() && !present[k.Key] {
+			report(0, "%s: required by the registry%s", k.Key, k.owner())
+		}
+	}
+	return diagnostics
+}
+
+// owner returns ", owned by" and the owner of k for the diagnostics, ""
+// without owner.
+func (k *RegistryKey) owner() string {
+	if k.Owner == "" {
+		return ""
+	}
+	return ", owned by " + k.Owner
+}
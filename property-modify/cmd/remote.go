@@ -0,0 +1,182 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gpm"
+	"gpm/storage"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// REMOTE_CACHE_DIR is the directory of the remote defaults cache inside
+// the user cache directory.
+const REMOTE_CACHE_DIR = "gpm"
+
+// TOKEN_ENV is the environment variable holding the bearer token sent to
+// http(s) -input and -output URLs.
+const TOKEN_ENV = "GPM_TOKEN"
+
+// The storages of remote -input and -output files are shared, so that a
+// file written after it was read is only replaced if it didn't change.
+var (
+	httpStorage *storage.HTTP
+	s3Storage   *storage.S3
+)
+
+func init() {
+	networkResolvers = append(networkResolvers, "defaults-url", "merge-url", "registry-url", "rotate-vault", "storage-http", "storage-s3")
+}
+
+// remoteStorage returns the storage of a http(s) or s3:// URL and the
+// name of the file in it. S3 uses the usual AWS_ environment variables,
+// AWS_ENDPOINT_URL for other services than AWS.
+func remoteStorage(url string) (gpm.Storage, string, error) {
+	object, ok := strings.CutPrefix(url, S3_SCHEME)
+	if !ok {
+		if httpStorage == nil {
+			httpStorage = storage.NewHTTP(os.Getenv(TOKEN_ENV))
+		}
+		return httpStorage, url, nil
+	}
+	if s3Storage == nil {
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := os.Getenv("AWS_ENDPOINT_URL")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if accessKey == "" || secretKey == "" {
+			return nil, "", fmt.Errorf("%s needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", url)
+		}
+		s3Storage = storage.NewS3(endpoint, region, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"))
+	}
+	return s3Storage, object, nil
+}
+
+// RemoteCache keeps the property files fetched from URLs on disk. A copy
+// younger than ttl is used without asking the host, an older one is
+// revalidated with its ETag, and when the host can't be reached the
+// cached copy is used whatever its age, so builds still work offline.
+// An offline cache never asks the host and fails for URLs it doesn't
+// have.
+type RemoteCache struct {
+	dir     string
+	ttl     time.Duration
+	offline bool
+	client  *http.Client
+}
+
+// cacheMeta is stored next to every cached file.
+type cacheMeta struct {
+	URL     string    `json:"url"`
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// NewRemoteCache returns a cache in dir, the gpm directory of the user
+// cache directory if dir is empty.
+func NewRemoteCache(dir string, ttl time.Duration, offline bool) (*RemoteCache, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(base, REMOTE_CACHE_DIR)
+	}
+	return &RemoteCache{
+		dir:     dir,
+		ttl:     ttl,
+		offline: offline,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Fetch returns the content at url, from the cache when possible.
+func (c *RemoteCache) Fetch(url string) ([]byte, error) {
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+	dataFile, metaFile := base+".properties", base+".json"
+
+	var meta cacheMeta
+	data, err := os.ReadFile(dataFile)
+	cached := err == nil && loadCacheMeta(metaFile, &meta) == nil
+	if cached && (c.offline || time.Since(meta.Fetched) < c.ttl) {
+		return data, nil
+	}
+	if c.offline {
+		return nil, fmt.Errorf("%s is not cached and offline mode forbids fetching it", url)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached && meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return stale(url, data, cached, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return stale(url, data, cached, err)
+		}
+		data = body
+		meta.ETag = resp.Header.Get("ETag")
+		if err := os.MkdirAll(c.dir, 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(dataFile, data, 0o644); err != nil {
+			return nil, err
+		}
+	default:
+		return stale(url, data, cached, fmt.Errorf("%s", resp.Status))
+	}
+
+	meta.URL = url
+	meta.Fetched = time.Now()
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(metaFile, encoded, 0o644); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// stale returns the cached copy of url after a failed fetch, if there is
+// one.
+func stale(url string, data []byte, cached bool, err error) ([]byte, error) {
+	if !cached {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: using the cached copy of %s: %v\n", url, err)
+	return data, nil
+}
+
+func loadCacheMeta(path string, meta *cacheMeta) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, meta)
+}
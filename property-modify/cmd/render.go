@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const TEMPLATE_EXT = ".tmpl"
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, items []any) string {
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, sep)
+	},
+}
+
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	tmplFile := fs.String("template", "", "Go template of the property file, e.g. app.properties.tmpl")
+	dataFile := fs.String("data", "", "JSON or YAML data file. A list renders one output per item")
+	output := fs.String("output", "", "Output path, itself a template evaluated with each item, e.g. 'out/{{.flavor}}.properties'. Default is the template path without "+TEMPLATE_EXT)
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify render [options]")
+		fmt.Println("Render a property file template with Go template loops and conditionals over a data file.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *tmplFile == "" || *dataFile == "" {
+		fs.Usage()
+		return 2
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(*tmplFile, TEMPLATE_EXT)
+	}
+
+	tmpl, err := template.New(filepath.Base(*tmplFile)).Funcs(templateFuncs).ParseFiles(*tmplFile)
+	if err != nil {
+		fmt.Println("Error parsing template:", err)
+		return 2
+	}
+	outTmpl, err := template.New("output").Funcs(templateFuncs).Parse(*output)
+	if err != nil {
+		fmt.Println("Error parsing output path:", err)
+		return 2
+	}
+
+	var data any
+	if err := loadData(*dataFile, &data); err != nil {
+		fmt.Println("Error reading data file:", err)
+		return 2
+	}
+	items, ok := data.([]any)
+	if !ok {
+		items = []any{data}
+	}
+
+	written := make(map[string]bool)
+	for i, item := range items {
+		var path bytes.Buffer
+		if err := outTmpl.Execute(&path, item); err != nil {
+			fmt.Printf("Error rendering output path of item %d: %v\n", i, err)
+			return 1
+		}
+		if written[path.String()] {
+			fmt.Printf("Error: item %d renders to %s again, use a template in -output\n", i, path.String())
+			return 1
+		}
+		written[path.String()] = true
+
+		var content bytes.Buffer
+		if err := tmpl.Execute(&content, item); err != nil {
+			fmt.Printf("Error rendering item %d: %v\n", i, err)
+			return 1
+		}
+		if dir := filepath.Dir(path.String()); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				fmt.Println("Error creating output directory:", err)
+				return 1
+			}
+		}
+		if err := os.WriteFile(path.String(), content.Bytes(), 0o644); err != nil {
+			fmt.Println("Error writing output file:", err)
+			return 1
+		}
+		fmt.Println("Rendered", path.String())
+	}
+	return 0
+}
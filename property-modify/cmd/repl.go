@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+	"os"
+	"strings"
+)
+
+const replHelp = `commands:
+  get key               print the value of key
+  set key=value#comment set a property, the comment is optional
+  rm key                remove a property
+  rename old new        rename a key, keeping its position
+  list                  print the whole file
+  diff [snapshot]       show the changes since the last save or the snapshot
+  snapshot name         remember the current state as name
+  save [path]           write the file, or a copy of it to path
+  quit                  leave, twice if there are unsaved changes
+  help                  show this help
+
+get, set, rm and rename also take glob: and re: key patterns, e.g.
+rm glob:debug.* or rename re:old\.(.*) new.$1`
+
+// REPL is an interactive session over a single parsed property file.
+type REPL struct {
+	path     string
+	modifier *gpm.Modifier
+	// saved is the text of the file at the last save
+	saved   string
+	out     io.Writer
+	warned  bool
+	stopped bool
+	// journal records the operations since the last save, may be nil
+	journal *Journal
+}
+
+func runREPL(args []string) int {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	journal := fs.Bool("journal", true, "Record every change in file"+JOURNAL_EXT+" until it is saved, and replay the changes a crashed session left there")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify repl [options] file")
+		fs.PrintDefaults()
+		fmt.Println(replHelp)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	doc, err := parseInput(path)
+	if err != nil {
+		return 2
+	}
+	modifier := doc.Modifier
+
+	r := &REPL{
+		path:     path,
+		modifier: modifier,
+		saved:    modifier.Text(),
+		out:      os.Stdout,
+	}
+
+	if *journal {
+		j, pending, err := OpenJournal(path)
+		if err != nil {
+			fmt.Println("Error opening journal:", err)
+			return 2
+		}
+		defer j.Close()
+		if len(pending) > 0 {
+			fmt.Printf("Replaying %d unsaved changes from %s\n", len(pending), j.path)
+			for _, op := range pending {
+				if err := op.apply(r.modifier); err != nil {
+					fmt.Println("error:", err)
+				}
+			}
+		}
+		r.journal = j
+	}
+
+	r.Run(os.Stdin)
+	return 0
+}
+
+// Run reads commands from in until quit or end of input.
+func (r *REPL) Run(in io.Reader) {
+	buf := bufio.NewScanner(in)
+	for !r.stopped {
+		fmt.Fprint(r.out, "> ")
+		if !buf.Scan() {
+			fmt.Fprintln(r.out)
+			return
+		}
+		line := strings.TrimSpace(buf.Text())
+		if line == "" {
+			continue
+		}
+		if err := r.exec(line); err != nil {
+			fmt.Fprintln(r.out, "error:", err)
+		}
+	}
+}
+
+func (r *REPL) exec(line string) error {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	if name != "quit" && name != "exit" {
+		r.warned = false
+	}
+
+	switch name {
+	case "get":
+		k, err := gpm.CompileKeyMatcher(arg)
+		if err != nil {
+			return err
+		}
+		if k.IsExact() {
+			v, ok := r.modifier.Get(arg)
+			if !ok {
+				return fmt.Errorf("key %q not found", arg)
+			}
+			fmt.Fprintln(r.out, v)
+			return nil
+		}
+		keys := r.modifier.MatchingKeys(k)
+		if len(keys) == 0 {
+			return fmt.Errorf("no key matches %q", arg)
+		}
+		for _, key := range keys {
+			v, _ := r.modifier.Get(key)
+			fmt.Fprintf(r.out, "%s=%s\n", key, v)
+		}
+	case OP_TYPE_SET, OP_TYPE_RM, OP_TYPE_RENAME:
+		op, err := parseOperation(line)
+		if err != nil {
+			return err
+		}
+		if r.journal != nil {
+			if err := r.journal.Append(op); err != nil {
+				return err
+			}
+		}
+		return op.apply(r.modifier)
+	case "list":
+		fmt.Fprint(r.out, r.modifier.Text())
+	case "snapshot":
+		if arg == "" {
+			return fmt.Errorf("missing snapshot name")
+		}
+		r.modifier.Snapshot(arg)
+	case "diff":
+		var changes []gpm.Change
+		var err error
+		if arg == "" {
+			changes, err = r.diff()
+		} else {
+			changes, err = r.modifier.DiffSnapshot(arg)
+		}
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			fmt.Fprintln(r.out, c)
+		}
+	case "save":
+		path := r.path
+		if arg != "" {
+			path = arg
+		}
+		if err := saveOutput(path, r.modifier); err != nil {
+			return err
+		}
+		if path == r.path {
+			r.saved = r.modifier.Text()
+			if r.journal != nil {
+				if err := r.journal.Reset(); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprintln(r.out, "saved", path)
+	case "quit", "exit":
+		if r.modifier.Text() != r.saved && !r.warned {
+			r.warned = true
+			return fmt.Errorf("unsaved changes, save first or %s again to discard them", name)
+		}
+		if r.journal != nil {
+			r.journal.Remove()
+			r.journal = nil
+		}
+		r.stopped = true
+	case "help":
+		fmt.Fprintln(r.out, replHelp)
+	default:
+		return fmt.Errorf("unknown command %q, try help", name)
+	}
+	return nil
+}
+
+// diff compares the current properties with the last saved ones.
+func (r *REPL) diff() ([]gpm.Change, error) {
+	saved := gpm.NewParser()
+	if err := saved.Parse(strings.NewReader(r.saved)); err != nil {
+		return nil, err
+	}
+	current := gpm.NewParser()
+	if err := current.Parse(strings.NewReader(r.modifier.Text())); err != nil {
+		return nil, err
+	}
+	return gpm.DiffProperties(saved.GetProps(), current.GetProps()), nil
+}
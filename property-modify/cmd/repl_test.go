@@ -0,0 +1,62 @@
+package main
+
+import (
+	"gpm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		commands string
+		want     string // the output without prompts, %s for the path
+		saved    string
+	}{
+		{"get", "a=1\nb=\n", "get a\nget b\nget c\n", "1\n\nerror: key \"c\" not found\n", "a=1\nb=\n"},
+		{"diff duplicate key", "a=1\na=2\n", "set b=3\ndiff\n", "+ b=3\n", "a=1\na=2\n"},
+		{"set empty value", "a=1\n", "set a=\ndiff\nsave\n", "~ a: 1 -> \nsaved %s\n", "a=\n"},
+		{"rm and rename", "a=1\nb=2\n", "rm a\nrename b c\nsave\n", "saved %s\n", "c=2\n"},
+		{"disabled line", "#a=1\n", "get a\nset a=2\nsave\n", "error: key \"a\" not found\nsaved %s\n", "#a=1\na=2\n"},
+		{"glob", "x.a=1\nx.b=2\ny=3\n", "rm glob:x.*\nsave\n", "saved %s\n", "y=3\n"},
+		{"snapshot", "a=1\n", "snapshot s\nset a=2\ndiff s\n", "~ a: 1 -> 2\n", "a=1\n"},
+		{"quit unsaved", "a=1\n", "set a=2\nquit\nquit\nget a\n", "error: unsaved changes, save first or quit again to discard them\n", "a=1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "local.properties")
+			if err := os.WriteFile(path, []byte(tt.input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := gpm.ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			r := &REPL{path: path, modifier: doc.Modifier, saved: tt.input, out: &out}
+			r.Run(strings.NewReader(tt.commands))
+
+			var got strings.Builder
+			for _, line := range strings.SplitAfter(out.String(), "\n") {
+				for strings.HasPrefix(line, "> ") {
+					line = line[len("> "):]
+				}
+				got.WriteString(line)
+			}
+			want := strings.ReplaceAll(tt.want, "%s", path)
+			if strings.TrimRight(got.String(), "\n") != strings.TrimRight(want, "\n") {
+				t.Errorf("output = %q, want %q", got.String(), want)
+			}
+			saved, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(saved) != tt.saved {
+				t.Errorf("saved %q, want %q", saved, tt.saved)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Where rotate takes the new values from.
+const (
+	ROTATE_SOURCE_RANDOM = "random"
+	ROTATE_SOURCE_PLUGIN = "plugin"
+	ROTATE_SOURCE_VAULT  = "vault"
+)
+
+// ROTATE_KEY_ENV passes the rotated key to the -plugin command.
+const ROTATE_KEY_ENV = "GPM_ROTATE_KEY"
+
+// The Vault connection settings of -vault, like the vault command line.
+const (
+	VAULT_ADDR_ENV  = "VAULT_ADDR"
+	VAULT_TOKEN_ENV = "VAULT_TOKEN"
+)
+
+// RotationEntry is one line of the rotation audit log. It never holds
+// the values.
+type RotationEntry struct {
+	Time   time.Time `json:"time"`
+	File   string    `json:"file"`
+	Key    string    `json:"key"`
+	Source string    `json:"source"`
+	// Due is when the rotation was due, empty for a key never rotated
+	Due  string `json:"due,omitempty"`
+	Next string `json:"next"`
+}
+
+// rotation is a key due for rotation.
+type rotation struct {
+	key  string
+	due  time.Time
+	line int
+}
+
+func runRotate(args []string) int {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file of the secrets")
+	check := fs.Bool("check", false, "Only report the keys due for rotation, exit 1 if there is any")
+	length := fs.Int("length", 32, "Random bytes of a generated value, written in unpadded base64url")
+	plugin := fs.String("plugin", "", "Command printing the new value, run by the shell with the key in "+ROTATE_KEY_ENV+", instead of generating it")
+	vault := fs.String("vault", "", "Path of a Vault key-value secret, e.g. secret/data/app, whose field named after the key is the new value, read from "+VAULT_ADDR_ENV+" with "+VAULT_TOKEN_ENV)
+	auditFile := fs.String("audit-log", "", "Append a JSON line per rotated key, without its value, to this file, '-' for stdout")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify rotate [options]")
+		fmt.Println("Replace the values of the keys annotated '@rotate 90d' whose last rotation, their @modified")
+		fmt.Println("annotation, is older than the period, and date the rotation with @modified.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *plugin != "" && *vault != "" || *length < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	doc, err := parseInput(*input)
+	if err != nil {
+		return 2
+	}
+	now := time.Now()
+	var due []rotation
+	for _, p := range doc.Props() {
+		if p.Key() == "" {
+			continue
+		}
+		t, ok, err := p.RotationDue()
+		if err != nil {
+			fmt.Printf("Error: %s line %d: %s: %v\n", *input, p.LineNum(), p.Key(), err)
+			return 2
+		}
+		if ok && now.After(t) {
+			due = append(due, rotation{key: p.Key(), due: t, line: p.LineNum()})
+		}
+	}
+
+	if *check {
+		for _, r := range due {
+			if r.due.IsZero() {
+				fmt.Printf("%s:%d: %s was never rotated\n", *input, r.line, r.key)
+				continue
+			}
+			fmt.Printf("%s:%d: %s is overdue since %s\n", *input, r.line, r.key, r.due.Format(time.DateOnly))
+		}
+		if len(due) > 0 {
+			return 1
+		}
+		fmt.Println("No key is due for rotation")
+		return 0
+	}
+	if len(due) == 0 {
+		fmt.Println("No key is due for rotation")
+		return 0
+	}
+
+	source := ROTATE_SOURCE_RANDOM
+	switch {
+	case *plugin != "":
+		source = ROTATE_SOURCE_PLUGIN
+	case *vault != "":
+		source = ROTATE_SOURCE_VAULT
+	}
+	var entries []RotationEntry
+	for _, r := range due {
+		var value string
+		switch source {
+		case ROTATE_SOURCE_PLUGIN:
+			value, err = pluginValue(*plugin, r.key)
+		case ROTATE_SOURCE_VAULT:
+			value, err = vaultSecret(*vault, r.key)
+		default:
+			value, err = randomValue(*length)
+		}
+		if err == nil {
+			err = doc.SetProperty(r.key, value, nil)
+		}
+		if err == nil {
+			err = doc.SetAnnotation(r.key, gpm.ANNOTATION_MODIFIED, now.Format(time.DateOnly))
+		}
+		if err != nil {
+			fmt.Printf("Error rotating %s: %v\n", r.key, err)
+			return 1
+		}
+		entry := RotationEntry{Time: now, File: *input, Key: r.key, Source: source}
+		if !r.due.IsZero() {
+			entry.Due = r.due.Format(time.DateOnly)
+		}
+		for _, p := range doc.Props() {
+			if p.Key() == r.key {
+				next, _, _ := p.RotationDue()
+				entry.Next = next.Format(time.DateOnly)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := saveOutput(*input, doc.Modifier); err != nil {
+		return 1
+	}
+	for _, e := range entries {
+		fmt.Printf("Rotated %s, next rotation due %s\n", e.Key, e.Next)
+	}
+	if err := writeRotations(*auditFile, entries); err != nil {
+		fmt.Println("Error writing audit log:", err)
+		return 1
+	}
+	return 0
+}
+
+// randomValue returns n random bytes in unpadded base64url.
+func randomValue(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pluginValue runs command with key in ROTATE_KEY_ENV and returns the
+// first line it prints.
+func pluginValue(command, key string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), ROTATE_KEY_ENV+"="+key)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	value, _, _ := strings.Cut(string(out), "\n")
+	value = strings.TrimSuffix(value, "\r")
+	if value == "" {
+		return "", fmt.Errorf("%s printed no value", command)
+	}
+	return value, nil
+}
+
+// writeRotations appends entries to the audit log at path, stdout for
+// "-", nothing without path.
+func writeRotations(path string, entries []RotationEntry) error {
+	if path == "" {
+		return nil
+	}
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
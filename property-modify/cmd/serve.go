@@ -0,0 +1,348 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gpm"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MAX_BODY is the largest value a PUT request may set, in bytes.
+const MAX_BODY = 1 << 20
+
+// Server exposes a property file over HTTP:
+//
+//	GET    /properties        the whole file
+//	GET    /properties/{key}  the value of key
+//	PUT    /properties/{key}  set key to the request body, ?comment= sets the comment
+//	DELETE /properties/{key}  remove key
+//	GET    /events            server-sent events for every change
+//	GET    /history/{key}     the last values of key set or removed through the server
+//
+// Every response carries the ETag of the file content. Writes honor
+// If-Match and fail with 412 Precondition Failed when the file changed
+// since the client read it, reads honor If-None-Match.
+//
+// With an AuthConfig every request needs an "Authorization: Bearer" token
+// whose role allows it. Requests can be rate limited per client and
+// logged to an audit log.
+//
+// With a Journal every write is recorded before the file is saved, and
+// the writes a crashed server didn't save are replayed on startup.
+type Server struct {
+	path     string
+	mu       sync.Mutex
+	modifier *gpm.Modifier
+	events   *Broker
+	history  *ValueHistory
+	auth     *AuthConfig
+	limiter  *RateLimiter
+	auditLog io.Writer
+	auditMu  sync.Mutex
+	journal  *Journal
+}
+
+func init() {
+	registerCommand(Command{"serve", "Serve a property file over HTTP", runServe})
+	serverFeatures = append(serverFeatures, "serve", "serve-audit-log", "serve-auth", "serve-events", "serve-history", "serve-rate-limit")
+}
+
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	input := fs.String("input", "local.properties", "Property file to serve")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	rate := fs.Float64("rate", 0, "Requests per second allowed per IP address, and per token with -auth, 0 disables rate limiting")
+	burst := fs.Int("burst", 10, "Requests a client may make at once before -rate applies")
+	auditFile := fs.String("audit-log", "", "Append a JSON line per request to this file, '-' for stdout")
+	historySize := fs.Int("history", 20, "Values remembered per key for /history/{key}, 0 disables it")
+	authFile := fs.String("auth", "", "YAML or JSON file of the accepted bearer tokens and their roles (read, write, admin), the server is open without it")
+	journal := fs.Bool("journal", true, "Record every write in file"+JOURNAL_EXT+" until it is saved, and replay the writes a crashed server left there")
+	fs.Usage = func() {
+		fmt.Println("Usage: property-modify serve [options]")
+		fmt.Println("Serve the property file over HTTP at /properties and /properties/{key}.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	doc, err := parseInput(*input)
+	if err != nil {
+		return 2
+	}
+	s := &Server{
+		path:     *input,
+		modifier: doc.Modifier,
+		events:   NewBroker(),
+	}
+	if *journal {
+		if err := s.openJournal(); err != nil {
+			fmt.Println("Error replaying journal:", err)
+			return 2
+		}
+		defer s.journal.Close()
+	}
+	if *historySize > 0 {
+		s.history = NewValueHistory(*historySize)
+	}
+	if *authFile != "" {
+		s.auth, err = loadAuthConfig(*authFile)
+		if err != nil {
+			fmt.Println("Error reading auth config:", err)
+			return 2
+		}
+	}
+
+	if *rate > 0 {
+		s.limiter = NewRateLimiter(*rate, *burst)
+	}
+	switch *auditFile {
+	case "":
+	case "-":
+		s.auditLog = os.Stdout
+	default:
+		file, err := os.OpenFile(*auditFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Println("Error opening audit log:", err)
+			return 2
+		}
+		defer file.Close()
+		s.auditLog = file
+	}
+
+	fmt.Printf("Serving %s on http://%s/properties\n", *input, *addr)
+	if err := http.ListenAndServe(*addr, s.Handler()); err != nil {
+		fmt.Println("Error serving:", err)
+		return 1
+	}
+	return 0
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /properties", s.handleList)
+	mux.HandleFunc("GET /properties/{key}", s.handleGet)
+	mux.HandleFunc("PUT /properties/{key}", s.handleSet)
+	mux.HandleFunc("DELETE /properties/{key}", s.handleRemove)
+	mux.Handle("GET /events", s.events)
+	if s.history != nil {
+		mux.Handle("GET /history/{key}", s.history)
+	}
+	handler := s.rateLimit(mux)
+	if s.auth != nil {
+		// limit the requests per IP address before authenticating them
+		// too, so that guessing tokens is limited
+		handler = s.rateLimit(s.authenticate(handler))
+	}
+	return s.audit(handler)
+}
+
+func (s *Server) etag() string {
+	return `"` + s.modifier.Fingerprint() + `"`
+}
+
+// notModified answers 304 if the client already has the current content.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request) bool {
+	etag := s.etag()
+	w.Header().Set("ETag", etag)
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// preconditionFailed answers 412 if the client edits an outdated version.
+func (s *Server) preconditionFailed(w http.ResponseWriter, r *http.Request) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || matchETag(ifMatch, s.etag()) {
+		return false
+	}
+	w.Header().Set("ETag", s.etag())
+	http.Error(w, "the properties changed, fetch them again", http.StatusPreconditionFailed)
+	return true
+}
+
+// matchETag reports whether the If-Match or If-None-Match header value
+// contains etag.
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notModified(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, s.modifier.Text())
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.modifier.Get(r.PathValue("key"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if s.notModified(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, value)
+}
+
+func (s *Server) handleSet(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_BODY))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	key := r.PathValue("key")
+	if !s.authorizeWrite(w, r, key) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.preconditionFailed(w, r) {
+		return
+	}
+	var comment *string
+	if r.URL.Query().Has("comment") {
+		c := r.URL.Query().Get("comment")
+		comment = &c
+	}
+	value := strings.TrimRight(string(body), "\r\n")
+	old, existed := s.modifier.Get(key)
+	m := s.modifier.Clone()
+	if err := m.SetProperty(key, value, comment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.save(w, m, Operation{Type: OP_TYPE_SET, Key: key, Value: value, Comment: r.URL.Query().Get("comment")}) {
+		s.record(r, key, ValueRecord{Value: value})
+		s.events.Publish(ChangeEvent{
+			Type:     OP_TYPE_SET,
+			Key:      key,
+			OldValue: old,
+			NewValue: value,
+			Existed:  existed,
+			Actor:    actor(r),
+		})
+	}
+}
+
+func (s *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !s.authorizeWrite(w, r, key) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.preconditionFailed(w, r) {
+		return
+	}
+	old, _ := s.modifier.Get(key)
+	m := s.modifier.Clone()
+	if !m.RemoveProperty(key) {
+		http.NotFound(w, r)
+		return
+	}
+	if s.save(w, m, Operation{Type: OP_TYPE_RM, Key: key}) {
+		s.record(r, key, ValueRecord{Removed: true})
+		s.events.Publish(ChangeEvent{
+			Type:     OP_TYPE_RM,
+			Key:      key,
+			OldValue: old,
+			Existed:  true,
+			Actor:    actor(r),
+		})
+	}
+}
+
+// record adds a change made by r to the history of key.
+func (s *Server) record(r *http.Request, key string, rec ValueRecord) {
+	if s.history == nil {
+		return
+	}
+	rec.Time = time.Now()
+	rec.Actor = actor(r)
+	s.history.Record(key, rec)
+}
+
+// save writes m, the properties changed by op, to the file and answers
+// with the new ETag. The server serves m only once it is saved. With a
+// journal op is recorded first, and dropped once the file is saved or the
+// write failed, so that only a write cut short by a crash is replayed.
+func (s *Server) save(w http.ResponseWriter, m *gpm.Modifier, op Operation) bool {
+	if s.journal != nil {
+		if err := s.journal.Append(op); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+	}
+	err := saveOutput(s.path, m)
+	if s.journal != nil {
+		if err := s.journal.Reset(); err != nil {
+			fmt.Println("Error resetting journal:", err)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	s.modifier = m
+	w.Header().Set("ETag", s.etag())
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// openJournal opens the journal of the served file, and applies and saves
+// the writes a crashed server left in it.
+func (s *Server) openJournal() error {
+	j, pending, err := OpenJournal(s.path)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Replaying %d unsaved writes from %s\n", len(pending), j.path)
+		for _, op := range pending {
+			if err := op.apply(s.modifier); err != nil {
+				fmt.Println("error:", err)
+			}
+		}
+		if err := saveOutput(s.path, s.modifier); err != nil {
+			j.Close()
+			return err
+		}
+		if err := j.Reset(); err != nil {
+			j.Close()
+			return err
+		}
+	}
+	s.journal = j
+	return nil
+}
@@ -0,0 +1,156 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"gpm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns a Server of input, saved in a temporary file.
+func newTestServer(t *testing.T, input string) *Server {
+	t.Helper()
+	doc, err := gpm.ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{
+		path:     filepath.Join(t.TempDir(), "local.properties"),
+		modifier: doc.Modifier,
+		events:   NewBroker(),
+	}
+}
+
+func TestRateLimitBeforeAuthenticate(t *testing.T) {
+	s := newTestServer(t, "a=1\n")
+	s.auth = &AuthConfig{Tokens: []Token{{Name: "ci", Token: "s3cret", Role: ROLE_READ}}}
+	s.limiter = NewRateLimiter(0.001, 2)
+	handler := s.Handler()
+
+	get := func(bearer string) int {
+		r := httptest.NewRequest(http.MethodGet, "/properties/a", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if got := get("guess"); got != want {
+			t.Errorf("request %d with an unknown token: status %d, want %d", i+1, got, want)
+		}
+	}
+	if got := get("s3cret"); got != http.StatusTooManyRequests {
+		t.Errorf("request from the same address with a known token: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestFailedSaveKeepsProperties(t *testing.T) {
+	s := newTestServer(t, "a=1\n")
+	// a directory can't be written as a file
+	s.path = t.TempDir()
+	handler := s.Handler()
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPut, "/properties/a", strings.NewReader("2")),
+		httptest.NewRequest(http.MethodPut, "/properties/b", strings.NewReader("3")),
+		httptest.NewRequest(http.MethodDelete, "/properties/a", nil),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s %s: status %d, want %d", r.Method, r.URL, w.Code, http.StatusInternalServerError)
+		}
+	}
+	if got := s.modifier.Text(); got != "a=1\n" {
+		t.Errorf("properties after failed saves = %q, want %q", got, "a=1\n")
+	}
+}
+
+func TestSetBodyTooLarge(t *testing.T) {
+	s := newTestServer(t, "a=1\n")
+	body := io.LimitReader(neverEnding('x'), MAX_BODY+1)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/properties/a", body))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if v, _ := s.modifier.Get("a"); v != "1" {
+		t.Errorf("a = %q after a rejected PUT, want %q", v, "1")
+	}
+}
+
+// neverEnding reads as an endless run of its byte.
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestJournalReplayedOnStart(t *testing.T) {
+	s := newTestServer(t, "a=1\n")
+	journal := `{"type":"set","key":"b","value":"2"}` + "\n" + `{"type":"rm","key":"a"}` + "\n" + `{"type":"set","key":"c"`
+	if err := os.WriteFile(s.path+JOURNAL_EXT, []byte(journal), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.openJournal(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.journal.Close()
+
+	saved, err := os.ReadFile(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != "b=2\n" {
+		t.Errorf("file after replay = %q, want %q", saved, "b=2\n")
+	}
+	if got := s.modifier.Text(); got != "b=2\n" {
+		t.Errorf("served properties after replay = %q, want %q", got, "b=2\n")
+	}
+	if left, _ := os.ReadFile(s.path + JOURNAL_EXT); len(left) > 0 {
+		t.Errorf("journal after replay = %q, want it empty", left)
+	}
+}
+
+func TestJournalEmptyAfterWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   func(t *testing.T) string
+		status int
+	}{
+		{"saved", func(t *testing.T) string { return filepath.Join(t.TempDir(), "local.properties") }, http.StatusNoContent},
+		// a directory can't be written as a file
+		{"failed", func(t *testing.T) string { return t.TempDir() }, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, "a=1\n")
+			s.path = tt.path(t)
+			if err := s.openJournal(); err != nil {
+				t.Fatal(err)
+			}
+			defer s.journal.Close()
+
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/properties/a", strings.NewReader("2")))
+			if w.Code != tt.status {
+				t.Errorf("PUT: status %d, want %d", w.Code, tt.status)
+			}
+			if left, _ := os.ReadFile(s.path + JOURNAL_EXT); len(left) > 0 {
+				t.Errorf("journal after the write = %q, want it empty", left)
+			}
+		})
+	}
+}
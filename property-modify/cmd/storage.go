@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"gpm"
+	"strings"
+)
+
+// S3_SCHEME starts the -input and -output URLs of objects, s3://bucket/key.
+const S3_SCHEME = "s3://"
+
+// storageFor returns the storage of the -input or -output at path and
+// the name of the file in it: the local file system, or, outside of
+// gpm_core builds, an HTTP server for http(s) URLs and S3 for s3:// ones.
+func storageFor(path string) (gpm.Storage, string, error) {
+	if !isRemote(path) && !strings.HasPrefix(path, S3_SCHEME) {
+		return gpm.FileStorage{}, path, nil
+	}
+	if isOffline() {
+		return nil, "", fmt.Errorf("%s needs the network, but offline mode forbids it", path)
+	}
+	return remoteStorage(path)
+}
+
+// readInput returns the content of the -input at path, see storageFor.
+func readInput(path string) ([]byte, error) {
+	store, name, err := storageFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return store.Read(name)
+}
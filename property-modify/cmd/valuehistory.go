@@ -0,0 +1,103 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	serverFeatures = append(serverFeatures, "history-server")
+}
+
+// ValueRecord is a value a key had at some point.
+type ValueRecord struct {
+	Time    time.Time `json:"time"`
+	File    string    `json:"file,omitempty"`
+	Value   string    `json:"value,omitempty"`
+	Removed bool      `json:"removed,omitempty"`
+	Actor   string    `json:"actor,omitempty"`
+}
+
+// ValueHistory keeps the last values of every key seen by serve or drift
+// in memory, so that a flapping key can be inspected without git.
+type ValueHistory struct {
+	mu     sync.Mutex
+	size   int
+	values map[string][]ValueRecord
+}
+
+// NewValueHistory returns a history keeping up to size values per key.
+func NewValueHistory(size int) *ValueHistory {
+	return &ValueHistory{
+		size:   size,
+		values: make(map[string][]ValueRecord),
+	}
+}
+
+// Record adds a value of key, forgetting the oldest one if the history of
+// key is full.
+func (h *ValueHistory) Record(key string, r ValueRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	values := append(h.values[key], r)
+	if len(values) > h.size {
+		values = values[len(values)-h.size:]
+	}
+	h.values[key] = values
+}
+
+// Values returns the recorded values of key, oldest first.
+func (h *ValueHistory) Values(key string) []ValueRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]ValueRecord(nil), h.values[key]...)
+}
+
+// ServeHTTP answers GET /history/{key} with the values of key as JSON.
+func (h *ValueHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	values := h.Values(r.PathValue("key"))
+	if values == nil {
+		values = []ValueRecord{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(values)
+}
+
+// printServerHistory prints the values of key remembered by the server at
+// base, oldest first.
+func printServerHistory(base, key string) int {
+	resp, err := http.Get(strings.TrimRight(base, "/") + "/history/" + url.PathEscape(key))
+	if err != nil {
+		fmt.Println("Error reading history:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("Error reading history:", resp.Status)
+		return 1
+	}
+	var values []ValueRecord
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		fmt.Println("Error reading history:", err)
+		return 1
+	}
+	for _, v := range values {
+		change := fmt.Sprintf("%q", v.Value)
+		if v.Removed {
+			change = "removed"
+		}
+		source := v.Actor
+		if v.File != "" {
+			source = v.File
+		}
+		fmt.Printf("%s %s: %s\n", v.Time.Format(time.RFC3339), source, change)
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+//go:build !gpm_core
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultSecret returns the field of the key-value version 2 secret at
+// path, e.g. "secret/data/app", of the Vault server at $VAULT_ADDR.
+func vaultSecret(path, field string) (string, error) {
+	if isOffline() {
+		return "", fmt.Errorf("offline mode forbids reading %s from Vault", path)
+	}
+	addr := os.Getenv(VAULT_ADDR_ENV)
+	if addr == "" {
+		return "", fmt.Errorf("%s is not set", VAULT_ADDR_ENV)
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv(VAULT_TOKEN_ENV))
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("reading %s from Vault: %s", path, resp.Status)
+	}
+	var secret struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("reading %s from Vault: %w", path, err)
+	}
+	value, ok := secret.Data.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%s has no string field %q", path, field)
+	}
+	return value, nil
+}
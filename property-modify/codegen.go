@@ -0,0 +1,106 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateGo writes a Go source file of package pkg declaring one constant
+// per property. The @type annotation selects the constant's type, values
+// of properties annotated with @secret are never emitted. The last line of
+// a key set on several lines wins, and keys converting to the same Go
+// identifier, like "a.b" and "a_b", are an error.
+func GenerateGo(w io.Writer, pkg string, props []Property) error {
+	last := make(map[string]int, len(props))
+	for i, p := range props {
+		if p.key != "" {
+			last[p.key] = i
+		}
+	}
+	idents := make(map[string]string, len(last))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gpm. DO NOT EDIT.\n\npackage %s\n\nconst (\n", pkg)
+	for i, p := range props {
+		if p.key == "" || last[p.key] != i {
+			continue
+		}
+		a := p.Annotations()
+		if a.Has(ANNOTATION_SECRET) {
+			continue
+		}
+		typ, _ := a.Get(ANNOTATION_TYPE)
+		literal, err := goLiteral(typ, p.value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", p.lineNum, p.key, err)
+		}
+		ident := goIdentifier(p.key)
+		if other, ok := idents[ident]; ok {
+			return fmt.Errorf("line %d: keys %q and %q both convert to %s", p.lineNum, other, p.key, ident)
+		}
+		idents[ident] = p.key
+		fmt.Fprintf(&buf, "\t%s = %s\n", ident, literal)
+	}
+	buf.WriteString(")\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func goLiteral(typ, value string) (string, error) {
+	switch typ {
+	case "int":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "float":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(n, 'g', -1, 64)), nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	}
+	return strconv.Quote(value), nil
+}
+
+// goIdentifier converts a property key like "app.version-code" to an
+// exported Go identifier like "AppVersionCode".
+func goIdentifier(key string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(r) {
+			sb.WriteRune('P')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return "P"
+	}
+	return sb.String()
+}
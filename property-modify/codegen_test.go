@@ -0,0 +1,59 @@
+package gpm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{"string", "app.name=demo\n", []string{`AppName = "demo"`}, ""},
+		{"empty value", "app.name=\n", []string{`AppName = ""`}, ""},
+		{"typed", "# @type int\nversion-code=3\n", []string{"VersionCode = 3"}, ""},
+		{"leading digit", "1st=a\n", []string{`P1st = "a"`}, ""},
+		{"duplicate key", "a=1\na=2\n", []string{`A = "2"`}, ""},
+		{"disabled line", "#a=1\nb=2\n", []string{`B = "2"`}, ""},
+		{"secret", "# @secret\npassword=hunter2\n", nil, ""},
+		{"duplicate secret", "password=x\n# @secret\npassword=hunter2\n", nil, ""},
+		{"bad type", "# @type int\nn=x\n", nil, "line 2: n:"},
+		{"dot and underscore", "a.b=1\na_b=2\n", nil, `keys "a.b" and "a_b" both convert to AB`},
+		{"dash and dot", "foo-bar=1\nfoo.bar=2\n", nil, `keys "foo-bar" and "foo.bar" both convert to FooBar`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = GenerateGo(&buf, "config", doc.Props())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GenerateGo error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			if n := strings.Count(got, " = "); n != len(tt.want) {
+				t.Errorf("GenerateGo declared %d constants, want %d:\n%s", n, len(tt.want), got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("GenerateGo missing %q:\n%s", want, got)
+				}
+			}
+			if strings.Contains(got, "hunter2") {
+				t.Errorf("GenerateGo emitted a secret:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package gpm
+
+// WithCommentBlocks makes the parsed document treat the comment lines
+// directly above a property as its documentation, see
+// Modifier.SetCommentBlocks.
+func WithCommentBlocks() ParserOption {
+	return func(p *Parser) {
+		p.commentBlocks = true
+	}
+}
+
+// SetCommentBlocks makes RemoveProperty remove the comment lines directly
+// above a key with it, the block Property.Doc returns for a parsed line.
+// Commented-out properties aren't part of it.
+func (m *Modifier) SetCommentBlocks(on bool) {
+	m.commentBlocks = on
+}
+
+// commentBlock returns the comment lines directly above e.
+func (m *Modifier) commentBlock(e *entry) []*entry {
+	var block []*entry
+	for other := e.prev; other != nil; other = other.prev {
+		if !other.IsCommentOnly() || other.IsDisabled() {
+			break
+		}
+		block = append(block, other)
+	}
+	return block
+}
+
+// SetPropertyWithDoc sets k to v like SetProperty. A new key is appended
+// after doc, written as one comment line per line, and Property.Doc
+// returns it.
+func (m *Modifier) SetPropertyWithDoc(k, v, doc string) error {
+	if _, ok := m.index[k]; ok || doc == "" {
+		return m.SetProperty(k, v, nil)
+	}
+	if err := m.validateKey(k); err != nil {
+		return err
+	}
+	m.add(m.commentLines(doc)...)
+	m.add(Property{
+		key:     k,
+		value:   v,
+		lineNum: NO_LINE,
+		marker:  m.commentPrefix,
+		doc:     doc,
+	})
+	return nil
+}
@@ -0,0 +1,81 @@
+package gpm
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Constraint rules reported by ConstraintError.
+const (
+	CONSTRAINT_MAX_LENGTH = "max-length"
+	CONSTRAINT_ASCII      = "ascii"
+	CONSTRAINT_CONTROL    = "control"
+)
+
+// Constraints restrict every value, for consumers that choke on long or
+// non-ASCII values. The zero value allows everything.
+type Constraints struct {
+	// MaxLength is the maximum number of characters of a value, 0 for no
+	// limit.
+	MaxLength int
+	// ASCIIOnly rejects characters outside of ASCII.
+	ASCIIOnly bool
+	// NoControl rejects control characters other than tab.
+	NoControl bool
+}
+
+// ConstraintError describes a value violating the Constraints.
+type ConstraintError struct {
+	Key   string
+	Value string
+	Rule  string // CONSTRAINT_MAX_LENGTH, CONSTRAINT_ASCII or CONSTRAINT_CONTROL
+	// Offset is the byte offset of the first offending character, or the
+	// length limit for CONSTRAINT_MAX_LENGTH.
+	Offset int
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.message())
+}
+
+func (e *ConstraintError) message() string {
+	switch e.Rule {
+	case CONSTRAINT_MAX_LENGTH:
+		return fmt.Sprintf("value is longer than %d characters", e.Offset)
+	case CONSTRAINT_ASCII:
+		return fmt.Sprintf("value has a non-ASCII character at offset %d", e.Offset)
+	}
+	return fmt.Sprintf("value has a control character at offset %d", e.Offset)
+}
+
+// Check returns the first constraint the value of key violates, or nil.
+func (c Constraints) Check(key, value string) *ConstraintError {
+	if c.MaxLength > 0 && utf8.RuneCountInString(value) > c.MaxLength {
+		return &ConstraintError{Key: key, Value: value, Rule: CONSTRAINT_MAX_LENGTH, Offset: c.MaxLength}
+	}
+	for i, r := range value {
+		if c.ASCIIOnly && r > unicode.MaxASCII {
+			return &ConstraintError{Key: key, Value: value, Rule: CONSTRAINT_ASCII, Offset: i}
+		}
+		if c.NoControl && r != '\t' && unicode.IsControl(r) {
+			return &ConstraintError{Key: key, Value: value, Rule: CONSTRAINT_CONTROL, Offset: i}
+		}
+	}
+	return nil
+}
+
+// SetConstraints makes SetPropertyChecked, SetProperties and Validate
+// enforce c.
+func (m *Modifier) SetConstraints(c Constraints) {
+	m.constraints = c
+}
+
+// SetPropertyChecked is SetProperty failing with a *ConstraintError,
+// without changing anything, if v violates the constraints.
+func (m *Modifier) SetPropertyChecked(k, v string, comment *string) error {
+	if err := m.constraints.Check(k, v); err != nil {
+		return err
+	}
+	return m.SetProperty(k, v, comment)
+}
@@ -0,0 +1,103 @@
+package gpm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// referenceRe matches ${key} references inside values.
+var referenceRe = regexp.MustCompile(`\$\{([^{}]+)\}`)
+
+// References returns the keys referenced as ${key} in value.
+func References(value string) []string {
+	var refs []string
+	for _, m := range referenceRe.FindAllStringSubmatch(value, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// CycleError is returned when ${key} references form a cycle.
+type CycleError struct {
+	Keys []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("reference cycle between %s", strings.Join(e.Keys, ", "))
+}
+
+// SortByReferences moves properties so that every key comes after the keys
+// it references with ${key}, otherwise keeping the file order. Comment
+// lines above a key move with it. References to keys that are not
+// defined in the file are ignored. If the references form a cycle nothing
+// is moved and a *CycleError is returned.
+func (m *Modifier) SortByReferences() error {
+	index := make(map[string]int)
+	var keys []string
+	for _, p := range m.lines() {
+		if p.key == "" {
+			continue
+		}
+		if _, ok := index[p.key]; !ok {
+			index[p.key] = len(keys)
+			keys = append(keys, p.key)
+		}
+	}
+
+	// edges from a definition to the keys referencing it
+	users := make([][]int, len(keys))
+	pending := make([]int, len(keys))
+	for _, key := range keys {
+		p := m.index[key]
+		seen := make(map[int]bool)
+		for _, ref := range References(p.value) {
+			def, ok := index[ref]
+			if !ok || seen[def] {
+				continue
+			}
+			seen[def] = true
+			users[def] = append(users[def], index[key])
+			pending[index[key]]++
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest ready key to keep
+	// the file order where possible
+	var ready []int
+	for i, n := range pending {
+		if n == 0 {
+			ready = append(ready, i)
+		}
+	}
+	rank := make(map[string]int, len(keys))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		next := ready[0]
+		ready = ready[1:]
+		rank[keys[next]] = len(rank)
+		for _, user := range users[next] {
+			pending[user]--
+			if pending[user] == 0 {
+				ready = append(ready, user)
+			}
+		}
+	}
+
+	if len(rank) < len(keys) {
+		var cycle []string
+		for i, key := range keys {
+			if pending[i] > 0 {
+				cycle = append(cycle, key)
+			}
+		}
+		return &CycleError{Keys: cycle}
+	}
+
+	m.orderBy(func(key string) (int, bool) {
+		r, ok := rank[key]
+		return r, ok
+	})
+	return nil
+}
@@ -0,0 +1,73 @@
+package gpm
+
+import (
+	"fmt"
+)
+
+const (
+	CHANGE_ADDED   = "added"
+	CHANGE_REMOVED = "removed"
+	CHANGE_CHANGED = "changed"
+)
+
+// Change is the difference of one key between two sets of properties.
+type Change struct {
+	Type     string // "added", "removed" or "changed"
+	Key      string
+	OldValue string // empty for "added"
+	NewValue string // empty for "removed"
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case CHANGE_ADDED:
+		return fmt.Sprintf("+ %s=%s", c.Key, c.NewValue)
+	case CHANGE_REMOVED:
+		return fmt.Sprintf("- %s=%s", c.Key, c.OldValue)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Key, c.OldValue, c.NewValue)
+}
+
+// DiffProperties compares the values of the keys in old and new. Comments
+// and layout are ignored, and the last line of a key set on several lines
+// wins, like in Get. Changed and removed keys are reported in the order of
+// old, added keys follow in the order of new.
+func DiffProperties(old, new []Property) []Change {
+	oldValues, newValues := lastValues(old), lastValues(new)
+	reported := make(map[string]bool, len(oldValues))
+
+	var changes []Change
+	for _, p := range old {
+		if p.key == "" || reported[p.key] {
+			continue
+		}
+		reported[p.key] = true
+		was := oldValues[p.key]
+		v, ok := newValues[p.key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Type: CHANGE_REMOVED, Key: p.key, OldValue: was})
+		case v != was:
+			changes = append(changes, Change{Type: CHANGE_CHANGED, Key: p.key, OldValue: was, NewValue: v})
+		}
+	}
+	for _, p := range new {
+		if p.key == "" || reported[p.key] {
+			continue
+		}
+		reported[p.key] = true
+		changes = append(changes, Change{Type: CHANGE_ADDED, Key: p.key, NewValue: newValues[p.key]})
+	}
+	return changes
+}
+
+// lastValues maps the keys of props to the value of their last line.
+func lastValues(props []Property) map[string]string {
+	values := make(map[string]string, len(props))
+	for _, p := range props {
+		if p.key != "" {
+			values[p.key] = p.value
+		}
+	}
+	return values
+}
@@ -0,0 +1,61 @@
+package gpm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Change
+	}{
+		{"unchanged", "a=1\n", "a=1\n", nil},
+		{"changed", "a=1\n", "a=2\n", []Change{{Type: CHANGE_CHANGED, Key: "a", OldValue: "1", NewValue: "2"}}},
+		{"added and removed", "a=1\nb=2\n", "b=2\nc=3\n", []Change{
+			{Type: CHANGE_REMOVED, Key: "a", OldValue: "1"},
+			{Type: CHANGE_ADDED, Key: "c", NewValue: "3"},
+		}},
+		{"duplicate key", "a=1\na=2\n", "a=1\na=2\nb=3\n", []Change{{Type: CHANGE_ADDED, Key: "b", NewValue: "3"}}},
+		{"duplicate key dropped", "a=1\na=2\n", "a=2\n", nil},
+		{"duplicate key changed", "a=1\na=2\n", "a=1\na=3\n", []Change{{Type: CHANGE_CHANGED, Key: "a", OldValue: "2", NewValue: "3"}}},
+		{"added twice", "", "a=1\na=2\n", []Change{{Type: CHANGE_ADDED, Key: "a", NewValue: "2"}}},
+		{"emptied", "a=1\n", "a=\n", []Change{{Type: CHANGE_CHANGED, Key: "a", OldValue: "1"}}},
+		{"comments ignored", "# x\na=1\n", "a=1 # y\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, err := ParseString(tt.old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			new, err := ParseString(tt.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DiffProperties(old.Props(), new.Props()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffProperties = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffSnapshotDuplicateKey(t *testing.T) {
+	doc, err := ParseString("a=1\na=2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Snapshot("input")
+	if err := doc.SetProperty("b", "3", nil); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := doc.DiffSnapshot("input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{{Type: CHANGE_ADDED, Key: "b", NewValue: "3"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("DiffSnapshot = %v, want %v", changes, want)
+	}
+}
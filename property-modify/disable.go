@@ -0,0 +1,111 @@
+package gpm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DISABLED_MARKER ends the comment of a line commented out by
+// DisableProperty.
+const DISABLED_MARKER = "disabled by gpm"
+
+// commentMarker returns the prefix of the comments m adds.
+func (m *Modifier) commentMarker() string {
+	if m.commentPrefix == "" {
+		return string(COMMENT)
+	}
+	return m.commentPrefix
+}
+
+// disabledSuffix returns what DisableProperty appends to the lines it
+// comments out.
+func (m *Modifier) disabledSuffix() string {
+	return "  " + m.commentMarker() + " " + DISABLED_MARKER
+}
+
+// DisableProperty comments out the line of key instead of removing it,
+// keeping its value for EnableProperty: "key=value" becomes
+// "#key=value  # disabled by gpm". It reports whether key existed.
+func (m *Modifier) DisableProperty(key string) bool {
+	e, ok := m.index[key]
+	if !ok {
+		return false
+	}
+	text := e.String()
+	m.unindex(e)
+	e.Property = Property{
+		comment:      text + m.disabledSuffix(),
+		hasComment:   true,
+		tightComment: true,
+		marker:       m.commentPrefix,
+		lineNum:      e.lineNum,
+	}
+	return true
+}
+
+// Disabled returns the property a comment line comments out, like
+// "#key=value", and whether it is one: the text of the comment must be a
+// key without blanks, a '=' and a value. A trailing "# disabled by gpm"
+// isn't part of the property.
+func (p *Property) Disabled() (Property, bool) {
+	prop, ok := p.disabled()
+	if ok && prop.comment == DISABLED_MARKER {
+		prop.comment, prop.hasComment = "", false
+	}
+	return prop, ok
+}
+
+// disabled is Disabled keeping the "# disabled by gpm" comment, so that
+// the comment line can be written back from the property.
+func (p *Property) disabled() (Property, bool) {
+	if !p.IsCommentOnly() {
+		return Property{}, false
+	}
+	parser := NewParser()
+	if p.marker != "" && p.marker != string(BANG) {
+		parser = NewParser(WithCommentPrefixes(p.marker))
+	}
+	prop := parser.parseTokens(rawLine(p.comment), p.lineNum)
+	if prop.key == "" || prop.separator == "" || strings.ContainsFunc(prop.key, unicode.IsSpace) {
+		return Property{}, false
+	}
+	return prop, true
+}
+
+// IsDisabled reports whether p is a commented-out property, see Disabled.
+func (p *Property) IsDisabled() bool {
+	_, ok := p.Disabled()
+	return ok
+}
+
+// DisabledProperties returns the commented-out properties, in file order,
+// as EnableProperty would restore them.
+func (m *Modifier) DisabledProperties() []Property {
+	var props []Property
+	for e := m.head; e != nil; e = e.next {
+		if prop, ok := e.Disabled(); ok {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// EnableProperty uncomments the last commented-out line of key, be it
+// disabled by DisableProperty or by hand, restoring its value. It reports
+// whether there was one; nothing is done if key is set.
+func (m *Modifier) EnableProperty(key string) bool {
+	if _, ok := m.index[key]; ok {
+		return false
+	}
+	for e := m.tail; e != nil; e = e.prev {
+		prop, ok := e.Disabled()
+		if !ok || prop.key != key {
+			continue
+		}
+		prop.prefixes = m.prefixes
+		e.Property = prop
+		m.index[key] = e
+		return true
+	}
+	return false
+}
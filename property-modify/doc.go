@@ -0,0 +1,7 @@
+// Package gpm parses, modifies and saves property files, keeping their
+// comments and layout.
+//
+// It only depends on the standard library and never uses the network:
+// the HTTP server, drift watcher and remote defaults live in the command
+// and can be left out of it with the gpm_core build tag.
+package gpm
@@ -0,0 +1,180 @@
+package gpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Document is a property file: its lines, parsed, modified and saved.
+// It replaces the Parser, GetProps and NewModifier steps with a
+// single Parse, and can be used as an ordered map of keys to values.
+// Comments and layout are kept. All Modifier methods are available.
+type Document struct {
+	*Modifier
+}
+
+// NewDocument returns an empty document.
+func NewDocument() *Document {
+	return newDocument(nil)
+}
+
+func newDocument(props []Property) *Document {
+	return &Document{Modifier: NewModifier(props)}
+}
+
+// parsedDocument returns the document of the lines read by parser, adding
+// comments in the style of the file.
+func parsedDocument(parser *Parser) *Document {
+	doc := newDocument(parser.GetProps())
+	doc.SetCommentPrefix(parser.commentPrefix())
+	doc.prefixes = parser.prefixes()
+	doc.SetEncoding(parser.Encoding())
+	doc.SetBOM(parser.HasBOM())
+	doc.SetLineEnding(parser.LineEnding())
+	doc.SetFinalNewline(parser.HasFinalNewline())
+	doc.SetCommentBlocks(parser.commentBlocks)
+	doc.SetQuotedValues(parser.quotedValues)
+	doc.SetUsage(parser.usage)
+	doc.SetKeyValidator(parser.validator())
+	return doc
+}
+
+// Parse reads a property file into a document ready to be modified.
+func Parse(r io.Reader, opts ...ParserOption) (*Document, error) {
+	parser := NewParser(opts...)
+	if err := parser.Parse(r); err != nil {
+		return nil, err
+	}
+	return parsedDocument(parser), nil
+}
+
+// ParseString parses s like Parse.
+func ParseString(s string, opts ...ParserOption) (*Document, error) {
+	return Parse(strings.NewReader(s), opts...)
+}
+
+// ParseBytes parses b like Parse.
+func ParseBytes(b []byte, opts ...ParserOption) (*Document, error) {
+	return Parse(bytes.NewReader(b), opts...)
+}
+
+// ParseFile reads the property file at path like Load, with the path in
+// its errors.
+func ParseFile(path string, opts ...ParserOption) (*Document, error) {
+	doc, err := Load(path, opts...)
+	var pathErr *fs.PathError
+	if err != nil && !errors.As(err, &pathErr) {
+		// the errors of os already have the path
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, err
+}
+
+// ParsePreserving reads a property file into a document that keeps the
+// layout of the lines it doesn't modify, see Parser.ParsePreserving.
+func ParsePreserving(r io.Reader, opts ...ParserOption) (*Document, error) {
+	parser := NewParser(opts...)
+	if err := parser.ParsePreserving(r); err != nil {
+		return nil, err
+	}
+	return parsedDocument(parser), nil
+}
+
+// Load reads the property file at path, as UTF-8 or ISO-8859-1, see
+// ENCODING_AUTO, unless opts set the encoding. Relative path values are
+// resolved against its directory, see Modifier.SetBaseDir.
+func Load(path string, opts ...ParserOption) (*Document, error) {
+	return LoadFrom(FileStorage{}, path, opts...)
+}
+
+// LoadFrom reads the property file name of s, like Load.
+func LoadFrom(s Storage, name string, opts ...ParserOption) (*Document, error) {
+	data, err := s.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]ParserOption{WithDecoding(ENCODING_AUTO)}, opts...)
+	doc, err := Parse(bytes.NewReader(data), opts...)
+	if err != nil {
+		return nil, err
+	}
+	doc.SetBaseDir(filepath.Dir(name))
+	doc.SetSource(name)
+	return doc, nil
+}
+
+// Save atomically writes doc to path, keeping the permissions of an
+// existing file.
+func Save(path string, doc *Document) error {
+	return SaveTo(FileStorage{}, path, doc)
+}
+
+// SaveTo writes doc to the file name of s, like Save.
+func SaveTo(s Storage, name string, doc *Document) error {
+	perm := fs.FileMode(0o644)
+	if info, err := s.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	var buf bytes.Buffer
+	if err := doc.Save(&buf); err != nil {
+		return err
+	}
+	return s.Write(name, buf.Bytes(), perm)
+}
+
+// Get returns the value of key in the property file at path.
+func Get(path, key string) (string, bool, error) {
+	doc, err := Load(path)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := doc.Get(key)
+	return value, ok, nil
+}
+
+// Set sets key to value in the property file at path, creating the file
+// if it doesn't exist.
+func Set(path, key, value string) error {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		doc := NewDocument()
+		if err := doc.Set(key, value); err != nil {
+			return err
+		}
+		return Save(path, doc)
+	}
+	return UpdateFile(path, func(m *Modifier) error {
+		return m.SetProperty(key, value, nil)
+	})
+}
+
+// Get returns the value of key.
+func (d *Document) Get(key string) (string, bool) {
+	return d.Modifier.Get(key)
+}
+
+// Set sets key to value, appending it if it is new, see SetProperty.
+func (d *Document) Set(key, value string) error {
+	return d.SetProperty(key, value, nil)
+}
+
+// Remove removes key and reports whether it existed.
+func (d *Document) Remove(key string) bool {
+	return d.RemoveProperty(key)
+}
+
+// Props returns the lines of the document, properties, comments and
+// blank lines, in file order.
+func (d *Document) Props() []Property {
+	return d.lines()
+}
+
+// AST returns the model of every line, as dumped by -dump-ast.
+func (d *Document) AST() []ASTEntry {
+	return astOf(d.lines())
+}
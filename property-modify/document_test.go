@@ -0,0 +1,100 @@
+package gpm
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetAndLoadLatin1(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		key   string
+		value string
+		want  string
+	}{
+		{"change", "greeting=caf\xe9\nname=Ren\xe9\n", "name", "Zoë", "greeting=caf\xe9\nname=Zo\xeb\n"},
+		{"add", "greeting=caf\xe9\n", "other", "x", "greeting=caf\xe9\nother=x\n"},
+		{"empty value", "greeting=caf\xe9\n", "greeting", "", "greeting=\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "messages.properties")
+			if err := os.WriteFile(path, []byte(tt.input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := Set(path, tt.key, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("saved %q, want %q", got, tt.want)
+			}
+			if value, ok, err := Get(path, tt.key); err != nil || !ok || value != tt.value {
+				t.Errorf("Get = %q, %v, %v, want %q", value, ok, err, tt.value)
+			}
+		})
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string // "" for no file
+		key   string
+		value string
+		want  string
+	}{
+		{"new file", "", "a", "1", "a=1\n"},
+		{"new key", "a=1\n", "b", "2", "a=1\nb=2\n"},
+		{"change", "# doc\na=1 # inline\n", "a", "2", "# doc\na=2 # inline\n"},
+		{"empty value", "a=1\n", "a", "", "a=\n"},
+		{"duplicate key", "a=1\na=2\n", "a", "3", "a=1\na=3\n"},
+		{"disabled line", "#a=1\n", "a", "2", "#a=1\na=2\n"},
+		{"no final newline", "a=1", "b", "2", "a=1\nb=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "local.properties")
+			if tt.input != "" {
+				if err := os.WriteFile(path, []byte(tt.input), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := Set(path, tt.key, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("saved %q, want %q", got, tt.want)
+			}
+			if value, ok, err := Get(path, tt.key); err != nil || !ok || value != tt.value {
+				t.Errorf("Get = %q, %v, %v, want %q", value, ok, err, tt.value)
+			}
+		})
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local.properties")
+	if _, _, err := Get(path, "a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get of a missing file: %v, want fs.ErrNotExist", err)
+	}
+	if err := os.WriteFile(path, []byte("#a=1\nb=\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if value, ok, err := Get(path, key); err != nil || ok {
+			t.Errorf("Get(%q) = %q, %v, %v, want not found", key, value, ok, err)
+		}
+	}
+}
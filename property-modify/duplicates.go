@@ -0,0 +1,110 @@
+package gpm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DUPLICATES_KEEP_ALL keeps every line of a key, the last one wins,
+	// the default
+	DUPLICATES_KEEP_ALL = "keep-all"
+	// DUPLICATES_KEEP_FIRST drops the lines setting a key again
+	DUPLICATES_KEEP_FIRST = "keep-first"
+	// DUPLICATES_KEEP_LAST drops the lines of a key set again later,
+	// like java.util.Properties
+	DUPLICATES_KEEP_LAST = "keep-last"
+	// DUPLICATES_ERROR reports the lines setting a key again as
+	// ParseErrors
+	DUPLICATES_ERROR = "error"
+)
+
+// Duplicate is a key set on several lines.
+type Duplicate struct {
+	Key   string
+	Lines []int
+}
+
+// WithDuplicates sets what the parser does with keys set on several
+// lines, DUPLICATES_KEEP_ALL by default. With DUPLICATES_ERROR, parsing
+// fails with ParseErrors for every line setting a key again, or stops at
+// the first one WithErrors(ERRORS_STRICT). ParseFunc can't keep the last
+// line of a key before reading the whole input, and keeps them all for
+// DUPLICATES_KEEP_LAST.
+func WithDuplicates(policy string) ParserOption {
+	return func(p *Parser) {
+		p.duplicatePolicy = policy
+	}
+}
+
+// Duplicates returns the keys set on several lines by the last parse, in
+// the order they are first set, whatever the policy.
+func (p *Parser) Duplicates() []Duplicate {
+	var duplicates []Duplicate
+	for _, key := range p.keys {
+		if lines := p.keyLines[key]; len(lines) > 1 {
+			duplicates = append(duplicates, Duplicate{Key: key, Lines: lines})
+		}
+	}
+	return duplicates
+}
+
+// duplicate records the line of prop and reports whether its key was set
+// before, with the error of DUPLICATES_ERROR.
+func (p *Parser) duplicate(prop *Property, raw string) (bool, *ParseError) {
+	if prop.key == "" {
+		return false, nil
+	}
+	lines, seen := p.keyLines[prop.key]
+	if !seen {
+		p.keys = append(p.keys, prop.key)
+	}
+	p.keyLines[prop.key] = append(lines, prop.lineNum)
+	if !seen || p.duplicatePolicy != DUPLICATES_ERROR {
+		return seen, nil
+	}
+	return true, &ParseError{
+		Line:   prop.lineNum,
+		Column: len([]rune(raw)) - len([]rune(strings.TrimLeft(raw, " \t\f"))) + 1,
+		Raw:    raw,
+		Reason: fmt.Sprintf("duplicate key %q, first set on line %d", prop.key, lines[0]),
+	}
+}
+
+// keepLast drops the lines of p.props whose key is set again later.
+func (p *Parser) keepLast() {
+	props := p.props[:0]
+	for _, prop := range p.props {
+		if lines := p.keyLines[prop.key]; prop.key == "" || lines[len(lines)-1] == prop.lineNum {
+			props = append(props, prop)
+		}
+	}
+	p.props = props
+}
+
+// Duplicates returns the keys set on several lines, in the order they are
+// first set.
+func (m *Modifier) Duplicates() []Duplicate {
+	if !m.duplicates {
+		return nil
+	}
+	m.number()
+	var keys []string
+	lines := make(map[string][]int)
+	for e := m.head; e != nil; e = e.next {
+		if e.key == "" {
+			continue
+		}
+		if _, ok := lines[e.key]; !ok {
+			keys = append(keys, e.key)
+		}
+		lines[e.key] = append(lines[e.key], e.lineNum)
+	}
+	var duplicates []Duplicate
+	for _, key := range keys {
+		if len(lines[key]) > 1 {
+			duplicates = append(duplicates, Duplicate{Key: key, Lines: lines[key]})
+		}
+	}
+	return duplicates
+}
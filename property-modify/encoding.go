@@ -0,0 +1,170 @@
+package gpm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ENCODING_UTF8   = "utf8"
+	ENCODING_LATIN1 = "latin1"
+	// ENCODING_AUTO reads a file as UTF-8 if it is valid UTF-8, as
+	// ISO-8859-1 otherwise.
+	ENCODING_AUTO = "auto"
+	// BOM starts the UTF-8 files written by some Windows editors
+	BOM = "\uFEFF"
+)
+
+// WithDecoding sets the encoding of the input: ENCODING_UTF8, the
+// default, ENCODING_LATIN1 for files written by java.util.Properties, or
+// ENCODING_AUTO to detect it. A Document is saved in the encoding it was
+// read with.
+func WithDecoding(encoding string) ParserOption {
+	return func(p *Parser) {
+		p.encoding = encoding
+	}
+}
+
+// Encoding returns the encoding of the parsed input, ENCODING_UTF8 or
+// ENCODING_LATIN1.
+func (p *Parser) Encoding() string {
+	if p.encoding == ENCODING_LATIN1 {
+		return ENCODING_LATIN1
+	}
+	return ENCODING_UTF8
+}
+
+// HasBOM reports whether the parsed input started with a BOM. It is not
+// part of the first line, and a Document writes it back on save.
+func (p *Parser) HasBOM() bool {
+	return p.bom
+}
+
+// decode returns r as UTF-8 without BOM, resolving ENCODING_AUTO.
+func (p *Parser) decode(r io.Reader) (io.Reader, error) {
+	switch p.encoding {
+	case "", ENCODING_UTF8:
+		buf := bufio.NewReader(r)
+		if start, _ := buf.Peek(len(BOM)); string(start) == BOM {
+			p.bom = true
+			buf.Discard(len(BOM))
+		}
+		return buf, nil
+	case ENCODING_LATIN1:
+		return &latin1Reader{r: r}, nil
+	case ENCODING_AUTO:
+	default:
+		return nil, fmt.Errorf("unknown encoding %q, expected %s, %s or %s", p.encoding, ENCODING_UTF8, ENCODING_LATIN1, ENCODING_AUTO)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.encoding == ENCODING_AUTO && utf8.Valid(data) {
+		p.encoding = ENCODING_UTF8
+		if bytes.HasPrefix(data, []byte(BOM)) {
+			p.bom = true
+			data = data[len(BOM):]
+		}
+		return bytes.NewReader(data), nil
+	}
+	p.encoding = ENCODING_LATIN1
+	return strings.NewReader(decodeLatin1(data)), nil
+}
+
+// latin1Reader decodes ISO-8859-1 to UTF-8 as it reads.
+type latin1Reader struct {
+	r   io.Reader
+	in  []byte
+	out []byte
+}
+
+func (l *latin1Reader) Read(b []byte) (int, error) {
+	for len(l.out) == 0 {
+		if l.in == nil {
+			l.in = make([]byte, 4096)
+		}
+		n, err := l.r.Read(l.in)
+		for _, c := range l.in[:n] {
+			l.out = utf8.AppendRune(l.out, rune(c))
+		}
+		if n == 0 && err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, l.out)
+	l.out = l.out[n:]
+	return n, nil
+}
+
+func decodeLatin1(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, b := range data {
+		sb.WriteRune(rune(b))
+	}
+	return sb.String()
+}
+
+// encodeLatin1 returns s in ISO-8859-1. Characters it doesn't have are
+// written as \uXXXX escapes, like Properties.store() does.
+func encodeLatin1(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r == utf8.RuneError && !strings.HasPrefix(s[i:], string(utf8.RuneError)):
+			// not UTF-8, keep the byte
+			sb.WriteByte(s[i])
+		case r <= 0xFF:
+			sb.WriteByte(byte(r))
+		default:
+			sb.WriteString(EncodeUnicode(string(r)))
+		}
+	}
+	return sb.String()
+}
+
+// SetEncoding sets the encoding Save writes in, ENCODING_UTF8 or
+// ENCODING_LATIN1. Parse sets it to the encoding of the input.
+func (m *Modifier) SetEncoding(encoding string) {
+	m.encoding = encoding
+}
+
+// Encoding returns the encoding Save writes in.
+func (m *Modifier) Encoding() string {
+	if m.encoding == ENCODING_LATIN1 {
+		return ENCODING_LATIN1
+	}
+	return ENCODING_UTF8
+}
+
+// SetBOM sets whether Save starts with a UTF-8 BOM. Parse sets it if the
+// input started with one.
+func (m *Modifier) SetBOM(bom bool) {
+	m.bom = bom
+}
+
+// HasBOM reports whether Save starts with a UTF-8 BOM.
+func (m *Modifier) HasBOM() bool {
+	return m.bom
+}
+
+// WithBOM sets whether the output starts with a UTF-8 BOM, instead of
+// following the Modifier. It is never written in ISO-8859-1.
+func WithBOM(bom bool) SaveOption {
+	return func(c *saveConfig) {
+		c.bom = bom
+	}
+}
+
+// WithEncoding writes the properties in encoding, ENCODING_UTF8 or
+// ENCODING_LATIN1, instead of the encoding of the Modifier.
+func WithEncoding(encoding string) SaveOption {
+	return func(c *saveConfig) {
+		c.latin1 = encoding == ENCODING_LATIN1
+	}
+}
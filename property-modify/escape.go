@@ -0,0 +1,221 @@
+package gpm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// UnescapeJava decodes the escapes of java.util.Properties: \t, \n, \r,
+// \f, \uXXXX and a backslash before any other character, which stands
+// for that character, e.g. "\=" or "\:" in keys written by store().
+func UnescapeJava(s string) (string, error) {
+	if !strings.ContainsRune(s, ESCAPE) {
+		return s, nil
+	}
+	var sb strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != ESCAPE || i == len(runes)-1 {
+			sb.WriteRune(r)
+			continue
+		}
+		i++
+		switch runes[i] {
+		case 't':
+			sb.WriteRune('\t')
+		case 'n':
+			sb.WriteRune('\n')
+		case 'r':
+			sb.WriteRune('\r')
+		case 'f':
+			sb.WriteRune('\f')
+		case 'u':
+			code, ok := hexCode(runes, i+1)
+			if !ok {
+				return "", fmt.Errorf("malformed \\uxxxx escape at %d", i-1)
+			}
+			i += 4
+			if utf16.IsSurrogate(code) && i+2 < len(runes) && runes[i+1] == ESCAPE && runes[i+2] == 'u' {
+				if low, ok := hexCode(runes, i+3); ok {
+					if r := utf16.DecodeRune(code, low); r != unicode.ReplacementChar {
+						code = r
+						i += 6
+					}
+				}
+			}
+			sb.WriteRune(code)
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// hexCode parses the 4 hex digits at runes[at:].
+func hexCode(runes []rune, at int) (rune, bool) {
+	if at+4 > len(runes) {
+		return 0, false
+	}
+	code, err := strconv.ParseUint(string(runes[at:at+4]), 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return rune(code), true
+}
+
+// EscapeJava escapes s the way Properties.store() does: '=', ':', '#',
+// '!' and backslashes are escaped, control characters use \t, \n, \r and
+// \f, characters outside of printable ASCII become \uXXXX. In keys every
+// space is escaped, in values only a leading one.
+func EscapeJava(s string, key bool) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\', '=', ':', '#', '!':
+			sb.WriteRune(ESCAPE)
+			sb.WriteRune(r)
+		case ' ':
+			if key || i == 0 {
+				sb.WriteRune(ESCAPE)
+			}
+			sb.WriteRune(r)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, u := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&sb, `\u%04X`, u)
+				}
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// DecodeUnicode decodes the \uXXXX escapes of s, including surrogate
+// pairs, and leaves every other escape and malformed \u alone.
+func DecodeUnicode(s string) string {
+	if !strings.Contains(s, `\u`) {
+		return s
+	}
+	var sb strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != ESCAPE || i == len(runes)-1 {
+			sb.WriteRune(r)
+			continue
+		}
+		code, ok := hexCode(runes, i+2)
+		if runes[i+1] != 'u' || !ok {
+			// keep the escape, and an escaped backslash whole
+			sb.WriteRune(r)
+			sb.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+		i += 5
+		if utf16.IsSurrogate(code) && i+2 < len(runes) && runes[i+1] == ESCAPE && runes[i+2] == 'u' {
+			if low, ok := hexCode(runes, i+3); ok {
+				if r := utf16.DecodeRune(code, low); r != unicode.ReplacementChar {
+					code = r
+					i += 6
+				}
+			}
+		}
+		sb.WriteRune(code)
+	}
+	return sb.String()
+}
+
+// EncodeUnicode writes the characters of s outside of ASCII as \uXXXX
+// escapes, characters outside of the BMP as surrogate pairs, like
+// Properties.store() does.
+func EncodeUnicode(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if r <= unicode.MaxASCII {
+			sb.WriteRune(r)
+			continue
+		}
+		for _, u := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&sb, `\u%04X`, u)
+		}
+	}
+	return sb.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeUnescaped puts a backslash before the characters of s in special
+// that are not escaped yet.
+func escapeUnescaped(s, special string) string {
+	if !strings.ContainsAny(s, special) {
+		return s
+	}
+	var sb strings.Builder
+	escaped := false
+	for _, r := range s {
+		if !escaped && strings.ContainsRune(special, r) {
+			sb.WriteRune(ESCAPE)
+		}
+		escaped = !escaped && r == ESCAPE
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeComments puts a backslash before the comment prefixes of s that
+// are not escaped yet: every '#', or the other prefixes at the start of s
+// or after whitespace, where they would start a comment.
+func escapeComments(s, prefix string) string {
+	if prefix == string(COMMENT) {
+		return escapeUnescaped(s, prefix)
+	}
+	if !strings.Contains(s, prefix) {
+		return s
+	}
+	var sb strings.Builder
+	escaped, blank := false, true
+	for i, r := range s {
+		if !escaped && blank && strings.HasPrefix(s[i:], prefix) {
+			sb.WriteRune(ESCAPE)
+		}
+		escaped = !escaped && r == ESCAPE
+		blank = isBlank(r)
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// DecodedKey returns the key with its Java escapes decoded.
+func (p *Property) DecodedKey() (string, error) {
+	return UnescapeJava(p.key)
+}
+
+// DecodedValue returns the value with its Java escapes decoded.
+func (p *Property) DecodedValue() (string, error) {
+	return UnescapeJava(p.value)
+}
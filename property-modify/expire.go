@@ -0,0 +1,101 @@
+package gpm
+
+import (
+	"fmt"
+	"time"
+)
+
+const ANNOTATION_EXPIRES = "expires"
+
+// expiry returns the date of the @expires annotation of p, ok is false if
+// p has none.
+func expiry(p *Property, loc *time.Location) (t time.Time, ok bool, err error) {
+	v, ok := p.Annotations().Get(ANNOTATION_EXPIRES)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	t, err = time.ParseInLocation(time.DateOnly, v, loc)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("invalid @expires date %q, expected YYYY-MM-DD", v)
+	}
+	return t, true, nil
+}
+
+// Expired returns the properties whose @expires date is not after now.
+func (m *Modifier) Expired(now time.Time) []Property {
+	var expired []Property
+	for _, p := range m.lines() {
+		if p.key == "" {
+			continue
+		}
+		t, ok, err := expiry(&p, now.Location())
+		if ok && err == nil && !now.Before(t) {
+			expired = append(expired, p)
+		}
+	}
+	return expired
+}
+
+// PruneExpired removes the properties whose @expires date is not after
+// now, together with the comment lines above them that carry the
+// annotation. It returns the removed keys.
+func (m *Modifier) PruneExpired(now time.Time) []string {
+	expired := m.Expired(now)
+	if len(expired) == 0 {
+		return nil
+	}
+
+	lines := m.lines()
+	drop := make(map[int]bool)
+	keys := make([]string, 0, len(expired))
+	for _, p := range expired {
+		keys = append(keys, p.key)
+		idx := p.lineNum - 1
+		drop[idx] = true
+		for i := idx - 1; i >= 0 && lines[i].IsCommentOnly(); i-- {
+			if ParseAnnotations(lines[i].comment).Has(ANNOTATION_EXPIRES) {
+				drop[i] = true
+			}
+		}
+	}
+
+	props := lines[:0]
+	for i, p := range lines {
+		if !drop[i] {
+			props = append(props, p)
+		}
+	}
+	m.setLines(props)
+	return keys
+}
+
+// LintExpired reports properties whose @expires date is not after now and
+// @expires annotations that can't be parsed.
+func LintExpired(props []Property, now time.Time) []LintIssue {
+	var issues []LintIssue
+	for _, p := range props {
+		if p.key == "" {
+			continue
+		}
+		t, ok, err := expiry(&p, now.Location())
+		if !ok {
+			continue
+		}
+		if err != nil {
+			issues = append(issues, LintIssue{
+				Line:    p.lineNum,
+				Rule:    RULE_EXPIRED,
+				Message: fmt.Sprintf("%s: %v", p.key, err),
+			})
+			continue
+		}
+		if !now.Before(t) {
+			issues = append(issues, LintIssue{
+				Line:    p.lineNum,
+				Rule:    RULE_EXPIRED,
+				Message: fmt.Sprintf("%s expired on %s", p.key, t.Format(time.DateOnly)),
+			})
+		}
+	}
+	return issues
+}
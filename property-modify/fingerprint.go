@@ -0,0 +1,13 @@
+package gpm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fingerprint returns a hash of the text Save would write without
+// options, it changes whenever the content does.
+func (m *Modifier) Fingerprint() string {
+	sum := sha256.Sum256([]byte(m.Text()))
+	return hex.EncodeToString(sum[:16])
+}
@@ -1,3 +1,5 @@
 module gpm
 
 go 1.24.6
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,265 @@
+package gpmtest
+
+import (
+	"bytes"
+	"fmt"
+	"gpm"
+	"math/rand"
+	"reflect"
+	"strings"
+)
+
+const (
+	keyChars   = "abcdefghijklmnopqrstuvwxyz0123456789._-"
+	valueChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 .,:;/=+-_*@$%{}()[]\"'äöüé中文"
+	// escapes are written as is, \u00e9 spells the é of valueChars
+	escapes = `\\ \n \t \u00e9 \=`
+)
+
+// Document is a random valid property file. It implements
+// testing/quick.Generator, so it can be used as an argument of functions
+// checked by quick.Check:
+//
+//	quick.Check(func(d gpmtest.Document) bool {
+//		return gpmtest.CheckInvariants(d.Text) == nil
+//	}, nil)
+type Document struct {
+	Text string
+}
+
+// Generate implements testing/quick.Generator.
+func (Document) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(Document{Text: RandomDocument(r, size)})
+}
+
+// RandomDocument returns a property file of about size lines mixing
+// properties, comments and blank lines. Keys are unique, values contain
+// spaces, separators, escapes and non-ASCII characters. It can back
+// generators of other property based testing libraries.
+func RandomDocument(r *rand.Rand, size int) string {
+	var sb strings.Builder
+	keys := make(map[string]bool)
+	lines := r.Intn(size + 1)
+	for i := 0; i < lines; i++ {
+		switch n := r.Intn(10); {
+		case n == 0:
+			sb.WriteString("\n")
+		case n == 1:
+			fmt.Fprintf(&sb, "# %s\n", randomValue(r, 30))
+		default:
+			key := randomKey(r)
+			if keys[key] {
+				continue
+			}
+			keys[key] = true
+			sb.WriteString(key)
+			sb.WriteString([]string{"=", " = ", "= ", " ="}[r.Intn(4)])
+			sb.WriteString(randomValue(r, 40))
+			if r.Intn(4) == 0 {
+				fmt.Fprintf(&sb, " # %s", randomValue(r, 20))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func randomKey(r *rand.Rand) string {
+	n := 1 + r.Intn(20)
+	key := make([]byte, n)
+	for i := range key {
+		key[i] = keyChars[r.Intn(len(keyChars))]
+	}
+	return string(key)
+}
+
+func randomValue(r *rand.Rand, max int) string {
+	chars := []rune(valueChars)
+	escapeList := strings.Fields(escapes)
+	var sb strings.Builder
+	n := r.Intn(max + 1)
+	for i := 0; i < n; i++ {
+		if r.Intn(15) == 0 {
+			sb.WriteString(escapeList[r.Intn(len(escapeList))])
+			continue
+		}
+		sb.WriteRune(chars[r.Intn(len(chars))])
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// CheckInvariants checks that gpm handles input consistently: saving the
+// parsed input keeps every key and value, and saving is stable, i.e.
+// saving the saved output again doesn't change it.
+func CheckInvariants(input string) error {
+	parser := gpm.NewParser()
+	if err := parser.Parse(strings.NewReader(input)); err != nil {
+		return fmt.Errorf("parse: %w", err)
+	}
+	saved := MustParse(input).Text()
+	reparsed := gpm.NewParser()
+	if err := reparsed.Parse(strings.NewReader(saved)); err != nil {
+		return fmt.Errorf("parse saved output: %w", err)
+	}
+	if changes := gpm.DiffProperties(parser.GetProps(), reparsed.GetProps()); len(changes) > 0 {
+		return fmt.Errorf("save changed the properties: %v", changes)
+	}
+	return checkStable(saved)
+}
+
+// CheckTransform applies fn to the parsed input and checks that the
+// result saves to a file that parses back to the values fn left, and
+// whose save is stable. Use it to fuzz transforms built on gpm.
+func CheckTransform(input string, fn func(*gpm.Modifier) error) error {
+	m := MustParse(input)
+	if err := fn(m); err != nil {
+		return fmt.Errorf("transform: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := m.Save(&buf); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	saved := buf.String()
+	reparsed := gpm.NewParser()
+	if err := reparsed.Parse(strings.NewReader(saved)); err != nil {
+		return fmt.Errorf("parse saved output: %w", err)
+	}
+	for _, p := range reparsed.GetProps() {
+		if p.Key() == "" {
+			continue
+		}
+		if v, ok := m.Get(p.Key()); !ok || v != p.Value() {
+			return fmt.Errorf("line %d: saved %s=%q, but the transform left %q", p.LineNum(), p.Key(), p.Value(), v)
+		}
+	}
+	return checkStable(saved)
+}
+
+// checkStable checks that saving the parsed saved output gives it back.
+func checkStable(saved string) error {
+	if again := MustParse(saved).Text(); again != saved {
+		return fmt.Errorf("save is not stable\nfirst:  %q\nsecond: %q", saved, again)
+	}
+	return nil
+}
+
+// CheckOperations applies n random SetProperty, RemoveProperty and
+// RenameKey calls to the parsed input, mixing existing, removed and new
+// keys, and checks after each one that the Modifier agrees with a plain
+// ordered list of keys: every value, the file order of the keys and their
+// line numbers. The input must not set a key twice, like RandomDocument.
+func CheckOperations(input string, r *rand.Rand, n int) error {
+	m := MustParse(input)
+	var keys []string
+	values := make(map[string]string)
+	for key, p := range m.All() {
+		keys = append(keys, key)
+		values[key] = p.Value()
+	}
+	// gone are the keys removed or renamed, set again now and then
+	var gone []string
+	pick := func() string {
+		if len(keys) == 0 || r.Intn(4) == 0 {
+			if len(gone) > 0 && r.Intn(2) == 0 {
+				return gone[r.Intn(len(gone))]
+			}
+			return randomKey(r)
+		}
+		return keys[r.Intn(len(keys))]
+	}
+	remove := func(key string) {
+		for i, k := range keys {
+			if k == key {
+				keys = append(keys[:i], keys[i+1:]...)
+				break
+			}
+		}
+		delete(values, key)
+		gone = append(gone, key)
+	}
+
+	for i := 0; i < n; i++ {
+		key := pick()
+		_, exists := values[key]
+		var op string
+		switch r.Intn(3) {
+		case 0:
+			value := randomValue(r, 20)
+			op = fmt.Sprintf("SetProperty(%q, %q)", key, value)
+			if err := m.SetProperty(key, value, nil); err != nil {
+				return fmt.Errorf("operation %d, %s: %w", i+1, op, err)
+			}
+			if !exists {
+				keys = append(keys, key)
+			}
+			values[key] = value
+		case 1:
+			op = fmt.Sprintf("RemoveProperty(%q)", key)
+			if removed := m.RemoveProperty(key); removed != exists {
+				return fmt.Errorf("operation %d, %s returned %v", i+1, op, removed)
+			}
+			if exists {
+				remove(key)
+			}
+		default:
+			newKey := pick()
+			op = fmt.Sprintf("RenameKey(%q, %q)", key, newKey)
+			err := m.RenameKey(key, newKey, true)
+			if !exists {
+				if err == nil {
+					return fmt.Errorf("operation %d, %s renamed a missing key", i+1, op)
+				}
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("operation %d, %s: %w", i+1, op, err)
+			}
+			if key == newKey {
+				continue
+			}
+			value := values[key]
+			if _, ok := values[newKey]; ok {
+				remove(newKey)
+			}
+			for j, k := range keys {
+				if k == key {
+					keys[j] = newKey
+				}
+			}
+			delete(values, key)
+			values[newKey] = value
+		}
+		if err := checkModel(m, keys, values); err != nil {
+			return fmt.Errorf("after operation %d, %s: %w", i+1, op, err)
+		}
+	}
+	return nil
+}
+
+// checkModel checks that m has exactly keys, in that order, set to values,
+// and that its lines are numbered in order.
+func checkModel(m *gpm.Modifier, keys []string, values map[string]string) error {
+	i := 0
+	last := 0
+	for key, p := range m.All() {
+		if i >= len(keys) {
+			return fmt.Errorf("unexpected key %q", key)
+		}
+		if key != keys[i] {
+			return fmt.Errorf("key %d is %q, want %q", i+1, key, keys[i])
+		}
+		if p.LineNum() <= last {
+			return fmt.Errorf("%s is on line %d, after line %d", key, p.LineNum(), last)
+		}
+		last = p.LineNum()
+		if v, ok := m.Get(key); !ok || v != values[key] {
+			return fmt.Errorf("%s is %q, want %q", key, v, values[key])
+		}
+		i++
+	}
+	if i != len(keys) {
+		return fmt.Errorf("%d keys, want %d", i, len(keys))
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package gpmtest
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestRandomDocumentInvariants(t *testing.T) {
+	err := quick.Check(func(d Document) bool {
+		if err := CheckInvariants(d.Text); err != nil {
+			t.Logf("%v\n%s", err, d.Text)
+			return false
+		}
+		return true
+	}, &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRandomValueEscapes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var sb strings.Builder
+	for i := 0; i < 200; i++ {
+		sb.WriteString(randomValue(r, 40))
+	}
+	values := sb.String()
+	for _, want := range []string{`\u00e9`, "é"} {
+		if !strings.Contains(values, want) {
+			t.Errorf("random values never contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package gpmtest helps testing code that manipulates property files with
+// gpm: builders for documents, assertions and a golden file harness.
+package gpmtest
+
+import (
+	"bytes"
+	"gpm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UPDATE_ENV is the environment variable that makes Golden rewrite the
+// golden files instead of comparing against them.
+const UPDATE_ENV = "GPM_UPDATE_GOLDEN"
+
+// MustParse parses s into a Modifier and panics on error.
+func MustParse(s string) *gpm.Modifier {
+	doc, err := gpm.Parse(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return doc.Modifier
+}
+
+// Text returns what m saves with opts, failing t on error.
+func Text(t testing.TB, m *gpm.Modifier, opts ...gpm.SaveOption) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := m.Save(&buf, opts...); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	return buf.String()
+}
+
+// AssertHasKey fails t unless m has key.
+func AssertHasKey(t testing.TB, m *gpm.Modifier, key string) {
+	t.Helper()
+	if _, ok := m.Get(key); !ok {
+		t.Errorf("key %q not found", key)
+	}
+}
+
+// AssertNoKey fails t if m has key.
+func AssertNoKey(t testing.TB, m *gpm.Modifier, key string) {
+	t.Helper()
+	if v, ok := m.Get(key); ok {
+		t.Errorf("key %q unexpectedly present with value %q", key, v)
+	}
+}
+
+// AssertValue fails t unless key has the value want in m.
+func AssertValue(t testing.TB, m *gpm.Modifier, key, want string) {
+	t.Helper()
+	got, ok := m.Get(key)
+	if !ok {
+		t.Errorf("key %q not found, want value %q", key, want)
+		return
+	}
+	if got != want {
+		t.Errorf("value of %q = %q, want %q", key, got, want)
+	}
+}
+
+// AssertRoundTrip fails t unless parsing and saving input gives input
+// back unchanged.
+func AssertRoundTrip(t testing.TB, input string) {
+	t.Helper()
+	if got := Text(t, MustParse(input)); got != input {
+		t.Errorf("round trip changed the input\n got: %q\nwant: %q", got, input)
+	}
+}
+
+// Golden compares got with the file testdata/<name>.golden. When the
+// environment variable GPM_UPDATE_GOLDEN is set, it writes got to the
+// file instead.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UPDATE_ENV) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UPDATE_ENV)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file (set %s=1 to update it)\n got: %q\nwant: %q", name, UPDATE_ENV, got, want)
+	}
+}
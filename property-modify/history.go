@@ -0,0 +1,96 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// KeyChange is a commit that changed the value of a key.
+type KeyChange struct {
+	Commit  string
+	Author  string
+	Date    string // YYYY-MM-DD
+	Subject string
+	// OldValue and NewValue are the values before and after the commit,
+	// Existed and Exists tell whether the key was present at all.
+	OldValue string
+	NewValue string
+	Existed  bool
+	Exists   bool
+}
+
+// GitHistory walks the git history of the property file at path, oldest
+// commit first, and returns the commits that added, changed or removed
+// key. It needs the git executable.
+func GitHistory(path, key string) ([]KeyChange, error) {
+	dir, file := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	file = "./" + file
+
+	out, err := git(dir, "log", "--reverse", "--date=short", "--format=%H%x00%an%x00%ad%x00%s", "--", file)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []KeyChange
+	var value string
+	var exists bool
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log output: %q", line)
+		}
+
+		newValue, newExists := "", false
+		content, err := git(dir, "show", fields[0]+":"+file)
+		if err == nil {
+			parser := NewParser()
+			if err := parser.Parse(bytes.NewReader(content)); err != nil {
+				return nil, err
+			}
+			for _, p := range parser.GetProps() {
+				if p.key == key {
+					newValue, newExists = p.value, true
+				}
+			}
+		}
+		// git show fails when the commit deleted the file, the key is
+		// gone then
+
+		if newExists == exists && newValue == value {
+			continue
+		}
+		changes = append(changes, KeyChange{
+			Commit:   fields[0],
+			Author:   fields[1],
+			Date:     fields[2],
+			Subject:  fields[3],
+			OldValue: value,
+			NewValue: newValue,
+			Existed:  exists,
+			Exists:   newExists,
+		})
+		value, exists = newValue, newExists
+	}
+	return changes, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,31 @@
+package gpm
+
+import "iter"
+
+// All returns an iterator over the properties parsed, keyed by their key,
+// in file order. Comment and blank lines are skipped, and a key set on
+// several lines is yielded once per line.
+func (p *Parser) All() iter.Seq2[string, Property] {
+	return func(yield func(string, Property) bool) {
+		for _, prop := range p.props {
+			if prop.key != "" && !yield(prop.key, prop) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over the properties of m, keyed by their key,
+// in file order, without copying the lines like Document.Props. Comment
+// and blank lines are skipped, and a key set on several lines is yielded
+// once per line. m must not be modified while iterating.
+func (m *Modifier) All() iter.Seq2[string, Property] {
+	return func(yield func(string, Property) bool) {
+		m.number()
+		for e := m.head; e != nil; e = e.next {
+			if e.key != "" && !yield(e.key, e.Property) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gpm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// KeyValidator returns why key is invalid, nil if it is valid.
+type KeyValidator func(key string) error
+
+// ValidateKey accepts the keys the parser reads back as written, the
+// default: not empty, without control characters, whitespace at either end
+// or a backslash at the end. Whitespace and ':' inside the key are kept,
+// only '=' ends it. Keys read with WithJavaSeparators are checked with
+// ValidateJavaKey instead.
+func ValidateKey(key string) error {
+	if key == "" {
+		return errors.New("empty key")
+	}
+	if strings.TrimLeft(key, javaBlanks) != key || strings.TrimRight(key, javaBlanks) != key {
+		return errors.New("whitespace at the start or end of the key, which is read as part of the separator")
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) && r != '\t' && r != '\f' {
+			return fmt.Errorf("control character %U in the key", r)
+		}
+	}
+	if trailing := len(key) - len(strings.TrimRight(key, string(ESCAPE))); trailing%2 == 1 {
+		return errors.New("backslash at the end of the key")
+	}
+	return nil
+}
+
+// ValidateJavaKey accepts the keys java.util.Properties reads back as
+// written: not empty, without control characters, and with a backslash
+// before any whitespace or ':', which Java reads as the end of the key.
+// '=' needn't be escaped, SetProperty escapes it.
+func ValidateJavaKey(key string) error {
+	if key == "" {
+		return errors.New("empty key")
+	}
+	escaped := false
+	for _, r := range key {
+		switch {
+		case r == '\t' || r == '\f' || r == ' ':
+			if !escaped {
+				return errors.New(`whitespace in the key, escape it with '\'`)
+			}
+		case unicode.IsControl(r):
+			return fmt.Errorf("control character %U in the key", r)
+		case r == ':':
+			if !escaped {
+				return errors.New(`':' in the key, which Java reads as the separator, escape it with '\'`)
+			}
+		}
+		escaped = !escaped && r == ESCAPE
+	}
+	if escaped {
+		return errors.New("backslash at the end of the key")
+	}
+	return nil
+}
+
+// WithKeyValidator replaces ValidateKey, or ValidateJavaKey with
+// WithJavaSeparators, the check of the parsed keys with
+// WithErrors(ERRORS_COLLECT), which fails with ParseErrors for every
+// invalid key, or ERRORS_STRICT, which stops at the first one. nil accepts
+// every key. Like other malformed lines, invalid keys are read as well as
+// possible with ERRORS_IGNORE, the default. The parsed document checks the
+// keys it adds with v too.
+func WithKeyValidator(v KeyValidator) ParserOption {
+	return func(p *Parser) {
+		p.keyValidator = v
+		p.keyValidatorSet = true
+	}
+}
+
+// validator returns the validator of the keys of the parser, see
+// WithKeyValidator.
+func (p *Parser) validator() KeyValidator {
+	switch {
+	case p.keyValidatorSet:
+		return p.keyValidator
+	case p.javaSeparators:
+		return ValidateJavaKey
+	}
+	return ValidateKey
+}
+
+// validateKey returns the error of the key of prop, nil if it is valid.
+func (p *Parser) validateKey(prop *Property, raw string) *ParseError {
+	validate := p.validator()
+	if validate == nil || prop.key == "" {
+		return nil
+	}
+	err := validate(prop.key)
+	if err == nil {
+		return nil
+	}
+	return &ParseError{
+		Line:   prop.lineNum,
+		Column: len([]rune(raw)) - len([]rune(strings.TrimLeft(raw, " \t\f"))) + 1,
+		Raw:    raw,
+		Reason: err.Error(),
+	}
+}
+
+// SetKeyValidator sets the validator of the new keys of SetProperty,
+// RenameKey and the other methods adding keys, ValidateKey by default,
+// or the validator of the parser of the document. nil accepts every key.
+func (m *Modifier) SetKeyValidator(v KeyValidator) {
+	m.keyValidator = v
+}
+
+// validateKey returns why key can't be added, nil if it can.
+func (m *Modifier) validateKey(key string) error {
+	if m.keyValidator == nil {
+		return nil
+	}
+	if err := m.keyValidator(key); err != nil {
+		return fmt.Errorf("invalid key %q: %w", key, err)
+	}
+	return nil
+}
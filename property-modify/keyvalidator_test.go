@@ -0,0 +1,87 @@
+package gpm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key         string
+		valid, java bool
+	}{
+		{"app.version", true, true},
+		{"my key", true, false},
+		{`my\ key`, true, true},
+		{"a:b", true, false},
+		{`a\:b`, true, true},
+		{"a=b", true, true},
+		{"a\tb", true, false},
+		{"", false, false},
+		{" a", false, false},
+		{"a ", false, false},
+		{"a\x7fb", false, false},
+		{"a\nb", false, false},
+		{`a\`, false, false},
+		{`a\\`, true, true},
+		{"äöü", true, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateKey(tt.key); (err == nil) != tt.valid {
+			t.Errorf("ValidateKey(%q) = %v, want valid %v", tt.key, err, tt.valid)
+		}
+		if err := ValidateJavaKey(tt.key); (err == nil) != tt.java {
+			t.Errorf("ValidateJavaKey(%q) = %v, want valid %v", tt.key, err, tt.java)
+		}
+	}
+}
+
+func TestDefaultKeyValidator(t *testing.T) {
+	const input = "a\x7fb=1\nok=2\n"
+	doc, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("parsing with ERRORS_IGNORE: %v", err)
+	}
+	if got, _ := doc.Get("ok"); got != "2" {
+		t.Errorf("Get(ok) = %q, want %q", got, "2")
+	}
+
+	_, err = ParseString(input, WithErrors(ERRORS_COLLECT))
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 1 {
+		t.Errorf("parsing with ERRORS_COLLECT: %v, want an error for line 1", err)
+	}
+
+	if _, err := ParseString(input, WithErrors(ERRORS_COLLECT), WithKeyValidator(nil)); err != nil {
+		t.Errorf("parsing with ERRORS_COLLECT and no key validator: %v", err)
+	}
+}
+
+func TestSetParsedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		key     string
+		opts    []ParserOption
+		wantErr bool
+	}{
+		{"colon", "a:b=1\n", "a:b", nil, false},
+		{"space", "my key=1\n", "my key", nil, false},
+		{"java separators", "a\\:b=1\n", "a:b", []ParserOption{WithJavaSeparators()}, true},
+		{"java separators escaped", "a\\:b=1\n", `a\:b`, []ParserOption{WithJavaSeparators()}, false},
+		{"no validator", "a=1\n", " a", []ParserOption{WithKeyValidator(nil)}, false},
+		{"leading blank", "a=1\n", " a", nil, true},
+		{"empty value", "a:b=\n", "a:b", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseString(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.SetProperty(tt.key, "2", nil); (err != nil) != tt.wantErr {
+				t.Errorf("SetProperty(%q) = %v, want error %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package gpm
+
+import (
+	"io"
+	"strings"
+)
+
+const (
+	LINE_ENDING_LF   = "\n"
+	LINE_ENDING_CRLF = "\r\n"
+)
+
+// LineEnding returns the line ending of most lines of the parsed input,
+// LINE_ENDING_LF or LINE_ENDING_CRLF.
+func (p *Parser) LineEnding() string {
+	if p.crlf > p.lf {
+		return LINE_ENDING_CRLF
+	}
+	return LINE_ENDING_LF
+}
+
+// HasFinalNewline reports whether the parsed input ended with a line
+// ending, or was empty.
+func (p *Parser) HasFinalNewline() bool {
+	return !p.noFinalNewline
+}
+
+// tailReader remembers the last byte read.
+type tailReader struct {
+	r    io.Reader
+	read bool
+	last byte
+}
+
+func (t *tailReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	if n > 0 {
+		t.read = true
+		t.last = b[n-1]
+	}
+	return n, err
+}
+
+// SetFinalNewline sets whether Save ends the last line with a line
+// ending, as it does by default. Parse sets it to what the input does, so
+// that files without one keep it that way.
+func (m *Modifier) SetFinalNewline(final bool) {
+	m.noFinalNewline = !final
+}
+
+// HasFinalNewline reports whether Save ends the last line with a line
+// ending.
+func (m *Modifier) HasFinalNewline() bool {
+	return !m.noFinalNewline
+}
+
+// withFinalNewline is set by the Modifier.
+func withFinalNewline(final bool) SaveOption {
+	return func(c *saveConfig) {
+		c.noFinalNewline = !final
+	}
+}
+
+// SetLineEnding sets the line ending of the lines Save writes,
+// LINE_ENDING_LF or LINE_ENDING_CRLF. Lines kept as they were parsed keep
+// their own. Parse sets it to the line ending of most lines of the input.
+func (m *Modifier) SetLineEnding(ending string) {
+	m.lineEnding = ending
+}
+
+// LineEnding returns the line ending of the lines Save writes.
+func (m *Modifier) LineEnding() string {
+	if m.lineEnding == "" {
+		return LINE_ENDING_LF
+	}
+	return m.lineEnding
+}
+
+// WithLineEnding writes every line with ending, LINE_ENDING_LF or
+// LINE_ENDING_CRLF, converting the lines kept as they were parsed too.
+func WithLineEnding(ending string) SaveOption {
+	return func(c *saveConfig) {
+		c.lineEnding = ending
+	}
+}
+
+// withNewLines is WithLineEnding for the lines Save rewrites only, the
+// line ending of the Modifier.
+func withNewLines(ending string) SaveOption {
+	return func(c *saveConfig) {
+		c.newLines = ending
+	}
+}
+
+// convertLineEndings returns text with every line ending replaced by
+// ending.
+func convertLineEndings(text, ending string) string {
+	text = strings.ReplaceAll(text, LINE_ENDING_CRLF, LINE_ENDING_LF)
+	if ending == LINE_ENDING_LF {
+		return text
+	}
+	return strings.ReplaceAll(text, LINE_ENDING_LF, ending)
+}
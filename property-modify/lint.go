@@ -0,0 +1,71 @@
+package gpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	RULE_TRAILING_WHITESPACE = "trailing-whitespace"
+	RULE_TAB                 = "tab"
+	RULE_BLANK_LINES         = "blank-lines"
+	RULE_EXPIRED             = "expired"
+	RULE_UNREAD              = "unread"
+)
+
+// LintIssue is a problem found by Lint.
+type LintIssue struct {
+	Line    int // 1-based
+	Rule    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%d: [%s] %s", i.Line, i.Rule, i.Message)
+}
+
+// Lint reports the lines of r that Modifier.Normalize would change.
+func Lint(r io.Reader, opts NormalizeOptions) ([]LintIssue, error) {
+	var issues []LintIssue
+	blanks := 0
+	lineNum := 0
+	buf := bufio.NewScanner(r)
+	for buf.Scan() {
+		lineNum++
+		line := buf.Text()
+
+		if strings.TrimSpace(line) == "" {
+			blanks++
+			if opts.MaxBlankLines >= 0 && blanks == opts.MaxBlankLines+1 {
+				issues = append(issues, LintIssue{
+					Line:    lineNum,
+					Rule:    RULE_BLANK_LINES,
+					Message: fmt.Sprintf("more than %d consecutive blank lines", opts.MaxBlankLines),
+				})
+			}
+			continue
+		}
+		blanks = 0
+
+		if trimmed := strings.TrimRight(line, " \t"); len(trimmed) != len(line) {
+			issues = append(issues, LintIssue{
+				Line:    lineNum,
+				Rule:    RULE_TRAILING_WHITESPACE,
+				Message: "trailing whitespace",
+			})
+		}
+		if opts.TabWidth > 0 && strings.ContainsRune(line, '\t') {
+			issues = append(issues, LintIssue{
+				Line:    lineNum,
+				Rule:    RULE_TAB,
+				Message: "tab character",
+			})
+		}
+	}
+	if err := buf.Err(); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
@@ -0,0 +1,138 @@
+package gpm
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	MATCH_GLOB_PREFIX   = "glob:"
+	MATCH_REGEXP_PREFIX = "re:"
+)
+
+// KeyMatcher selects keys with a pattern. A pattern prefixed with "glob:"
+// is a path.Match pattern, one prefixed with "re:" a regular expression
+// that must match the whole key, and any other pattern is the key itself.
+type KeyMatcher struct {
+	pattern string
+	exact   bool
+	glob    string
+	re      *regexp.Regexp
+}
+
+// CompileKeyMatcher returns the matcher of pattern.
+func CompileKeyMatcher(pattern string) (*KeyMatcher, error) {
+	k := &KeyMatcher{pattern: pattern}
+	switch {
+	case strings.HasPrefix(pattern, MATCH_GLOB_PREFIX):
+		k.glob = strings.TrimPrefix(pattern, MATCH_GLOB_PREFIX)
+		if _, err := path.Match(k.glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q", pattern)
+		}
+	case strings.HasPrefix(pattern, MATCH_REGEXP_PREFIX):
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, MATCH_REGEXP_PREFIX) + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+		}
+		k.re = re
+	default:
+		k.exact = true
+	}
+	return k, nil
+}
+
+// CompileKeyGlob is CompileKeyMatcher for lists of key patterns, like the
+// one of -only-keys, where a pattern without prefix is a path.Match
+// pattern too.
+func CompileKeyGlob(pattern string) (*KeyMatcher, error) {
+	if strings.HasPrefix(pattern, MATCH_GLOB_PREFIX) || strings.HasPrefix(pattern, MATCH_REGEXP_PREFIX) {
+		return CompileKeyMatcher(pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid key pattern %q", pattern)
+	}
+	return &KeyMatcher{pattern: pattern, glob: pattern}, nil
+}
+
+// IsExact reports whether the matcher matches a single key, its pattern.
+func (k *KeyMatcher) IsExact() bool {
+	return k.exact
+}
+
+// String returns the pattern.
+func (k *KeyMatcher) String() string {
+	return k.pattern
+}
+
+// Match reports whether key is selected.
+func (k *KeyMatcher) Match(key string) bool {
+	switch {
+	case k.exact:
+		return key == k.pattern
+	case k.re != nil:
+		return k.re.MatchString(key)
+	}
+	ok, _ := path.Match(k.glob, key)
+	return ok
+}
+
+// Rename returns the new name of a selected key: newKey, where for a re:
+// pattern $1, ${name} and the like are replaced by the submatches of key.
+func (k *KeyMatcher) Rename(key, newKey string) string {
+	if k.re == nil {
+		return newKey
+	}
+	match := k.re.FindStringSubmatchIndex(key)
+	if match == nil {
+		return newKey
+	}
+	return string(k.re.ExpandString(nil, newKey, key, match))
+}
+
+// KeyMatchers selects the keys selected by any of its matchers.
+type KeyMatchers []*KeyMatcher
+
+// CompileKeyGlobs returns the matchers of patterns, see CompileKeyGlob.
+func CompileKeyGlobs(patterns []string) (KeyMatchers, error) {
+	matchers := make(KeyMatchers, 0, len(patterns))
+	for _, pattern := range patterns {
+		k, err := CompileKeyGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, k)
+	}
+	return matchers, nil
+}
+
+// Match reports whether one of the matchers selects key.
+func (ks KeyMatchers) Match(key string) bool {
+	for _, k := range ks {
+		if k.Match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchingKeys returns the keys selected by k in file order, each once.
+func (m *Modifier) MatchingKeys(k *KeyMatcher) []string {
+	if k.exact {
+		if _, ok := m.index[k.pattern]; ok {
+			return []string{k.pattern}
+		}
+		return nil
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for e := m.head; e != nil; e = e.next {
+		if e.key == "" || seen[e.key] || !k.Match(e.key) {
+			continue
+		}
+		seen[e.key] = true
+		keys = append(keys, e.key)
+	}
+	return keys
+}
@@ -0,0 +1,90 @@
+package gpm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ANNOTATION_MODIFIED dates the last change of a value, "@modified
+// 2026-03-01" or an RFC 3339 time, for merges keeping the newest value.
+const ANNOTATION_MODIFIED = "modified"
+
+// Modified returns the time of the @modified annotation of p, ok is false
+// if p has none.
+func (p *Property) Modified() (t time.Time, ok bool, err error) {
+	v, ok := p.Annotations().Get(ANNOTATION_MODIFIED)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	if t, err = time.Parse(time.RFC3339, v); err == nil {
+		return t, true, nil
+	}
+	if t, err = time.Parse(time.DateOnly, v); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, true, fmt.Errorf("invalid @modified time %q, expected YYYY-MM-DD or RFC 3339", v)
+}
+
+// GitModified returns when the line of every key of the property file at
+// path was last changed according to git blame, the last line of a key
+// set several times. Lines that aren't committed yet are dated now. It
+// needs the git executable.
+func GitModified(path string) (map[string]time.Time, error) {
+	dir, file := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	out, err := git(dir, "blame", "--line-porcelain", "--", file)
+	if err != nil {
+		return nil, err
+	}
+
+	// every line of the file is a header, "author-time" among others and
+	// the line itself after a tab
+	var lineTimes []time.Time
+	var t time.Time
+	buf := bufio.NewScanner(bytes.NewReader(out))
+	buf.Buffer(nil, 1<<20)
+	for buf.Scan() {
+		line := buf.Text()
+		switch {
+		case strings.HasPrefix(line, "author-time "):
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git blame output: %q", line)
+			}
+			t = time.Unix(seconds, 0)
+		case strings.HasPrefix(line, "\t"):
+			lineTimes = append(lineTimes, t)
+		}
+	}
+	if err := buf.Err(); err != nil {
+		return nil, err
+	}
+
+	doc, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	times := make(map[string]time.Time)
+	line := 1
+	for _, p := range doc.Props() {
+		if p.key != "" {
+			// a continued line is as new as its newest physical line
+			var newest time.Time
+			for i := line; i < line+p.Lines() && i <= len(lineTimes); i++ {
+				if lineTimes[i-1].After(newest) {
+					newest = lineTimes[i-1]
+				}
+			}
+			times[p.key] = newest
+		}
+		line += p.Lines()
+	}
+	return times, nil
+}
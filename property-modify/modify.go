@@ -2,80 +2,624 @@ package gpm
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"maps"
 	"strings"
 )
 
-type Modifier struct {
-	props []Property
-	kv    map[string]Property
+// entry is a line of a Modifier. The lines are a doubly linked list, so
+// that removing a key or inserting lines next to one costs O(1) however
+// large the file is; line numbers are only recomputed when asked for.
+type entry struct {
+	Property
+	// prev and next are nil at the ends. A removed entry keeps its next,
+	// so that loops can remove the line they are at.
+	prev, next *entry
+}
 
-	// addProps    []Property
-	// removeProps []Property
+type Modifier struct {
+	// head and tail are the first and last lines, nil if there are none
+	head, tail *entry
+	// count is the number of lines
+	count int
+	// index maps every key to its last line
+	index map[string]*entry
+	// duplicates is set if a key has several lines
+	duplicates bool
+	// numbered is set while the lineNum of the entries are up to date
+	numbered bool
+	// snapshots are copies of the lines labeled by Snapshot
+	snapshots map[string][]Property
+	// constraints are enforced by SetPropertyChecked and Validate
+	constraints Constraints
+	// commentPrefix starts the comments added, "" for '#'
+	commentPrefix string
+	// prefixes are the comment prefixes of the parsed file, set on the
+	// lines added, see Property.prefixes
+	prefixes string
+	// encoding is the encoding of Save, "" for UTF-8
+	encoding string
+	// baseDir resolves relative path values, see SetBaseDir
+	baseDir string
+	// bom is set to start Save with a BOM
+	bom bool
+	// lineEnding ends the lines Save rewrites, "" for '\n'
+	lineEnding string
+	// source is the file of the lines, see SetSource
+	source string
+	// noFinalNewline is set to end Save without a line ending
+	noFinalNewline bool
+	// commentBlocks is set by SetCommentBlocks
+	commentBlocks bool
+	// keyValidator checks the keys added, see SetKeyValidator
+	keyValidator KeyValidator
+	// quotedValues is set by SetQuotedValues
+	quotedValues bool
+	// usage records the typed reads, see SetUsage
+	usage *Usage
 }
 
+// NewModifier returns a Modifier of props, indexed and ready to use.
+// Parse returns a Document doing the parsing too.
 func NewModifier(props []Property) *Modifier {
-	return &Modifier{
-		props: props[:],
-		kv:    make(map[string]Property),
+	m := &Modifier{keyValidator: ValidateKey}
+	m.setLines(props)
+	return m
+}
+
+// Prepare does nothing, NewModifier indexes the properties and every
+// change keeps the index up to date.
+//
+// Deprecated: it is no longer needed.
+func (m *Modifier) Prepare() {}
+
+// setLines replaces all lines by props, numbering and indexing them.
+func (m *Modifier) setLines(props []Property) {
+	m.head, m.tail, m.count = nil, nil, 0
+	m.index = make(map[string]*entry, len(props))
+	m.duplicates = false
+	m.add(props...)
+	m.number()
+}
+
+// number sets the lineNum of the lines if they changed.
+func (m *Modifier) number() {
+	if m.numbered {
+		return
+	}
+	line := 1
+	for e := m.head; e != nil; e = e.next {
+		e.lineNum = line
+		line++
+	}
+	m.numbered = true
+}
+
+// at returns the line at the 1-based line number line, nil if there is
+// none.
+func (m *Modifier) at(line int) *entry {
+	if line < 1 || line > m.count {
+		return nil
+	}
+	e := m.head
+	for ; line > 1; line-- {
+		e = e.next
+	}
+	return e
+}
+
+// Clone returns a copy of m, with its lines and settings, that changes
+// independently of m.
+func (m *Modifier) Clone() *Modifier {
+	c := *m
+	c.setLines(m.lines())
+	c.snapshots = maps.Clone(m.snapshots)
+	return &c
+}
+
+// lines returns a copy of the lines with their current line numbers.
+func (m *Modifier) lines() []Property {
+	m.number()
+	props := make([]Property, 0, m.count)
+	for e := m.head; e != nil; e = e.next {
+		props = append(props, e.Property)
+	}
+	return props
+}
+
+// add appends lines.
+func (m *Modifier) add(props ...Property) {
+	m.insertBefore(nil, props...)
+}
+
+// insertBefore inserts lines before mark, at the end if mark is nil.
+func (m *Modifier) insertBefore(mark *entry, props ...Property) {
+	for _, p := range props {
+		if p.prefixes == "" {
+			p.prefixes = m.prefixes
+		}
+		e := &entry{Property: p, next: mark}
+		if mark == nil {
+			e.prev = m.tail
+			m.tail = e
+		} else {
+			e.prev = mark.prev
+			mark.prev = e
+		}
+		if e.prev == nil {
+			m.head = e
+		} else {
+			e.prev.next = e
+		}
+		m.count++
+		if e.key != "" {
+			// the index has the last line of a key
+			last, ok := m.index[e.key]
+			if ok {
+				m.duplicates = true
+			}
+			if !ok || mark == nil || m.before(last, e) {
+				m.index[e.key] = e
+			}
+		}
+	}
+	m.numbered = false
+}
+
+// before reports whether a comes before b.
+func (m *Modifier) before(a, b *entry) bool {
+	for e := a.next; e != nil; e = e.next {
+		if e == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Modifier) remove(e *entry) {
+	if e.prev == nil {
+		m.head = e.next
+	} else {
+		e.prev.next = e.next
+	}
+	if e.next == nil {
+		m.tail = e.prev
+	} else {
+		e.next.prev = e.prev
 	}
+	m.count--
+	m.numbered = false
+	m.unindex(e)
 }
 
-func (m *Modifier) Prepare() {
-	for i, p := range m.props {
-		p.lineNum = i + 1
-		m.kv[p.key] = p
+func (m *Modifier) unindex(e *entry) {
+	if m.index[e.key] != e {
+		return
+	}
+	delete(m.index, e.key)
+	if !m.duplicates {
+		return
+	}
+	for other := m.head; other != nil; other = other.next {
+		if other != e && other.key == e.key {
+			m.index[e.key] = other
+		}
+	}
+}
+
+// Get returns the value of key.
+func (m *Modifier) Get(key string) (string, bool) {
+	e, ok := m.index[key]
+	if !ok {
+		return "", false
+	}
+	return e.value, true
+}
+
+// GetProperty returns the line setting key, the last one if there are
+// several, with its current line number.
+func (m *Modifier) GetProperty(key string) (Property, bool) {
+	e, ok := m.index[key]
+	if !ok {
+		return Property{}, false
+	}
+	m.number()
+	return e.Property, true
+}
+
+// Has reports whether key is set.
+func (m *Modifier) Has(key string) bool {
+	_, ok := m.index[key]
+	return ok
+}
+
+// Keys returns the keys in file order, a key set on several lines once.
+func (m *Modifier) Keys() []string {
+	keys := make([]string, 0, len(m.index))
+	seen := make(map[string]bool, len(m.index))
+	for e := m.head; e != nil; e = e.next {
+		if e.key != "" && !seen[e.key] {
+			seen[e.key] = true
+			keys = append(keys, e.key)
+		}
 	}
+	return keys
 }
 
-func (m *Modifier) SetProperty(k, v string, comment *string) {
+// Len returns the number of keys.
+func (m *Modifier) Len() int {
+	return len(m.index)
+}
+
+// SetCommentPrefix sets the prefix of the comments added by SetProperty,
+// AddComment and InsertComment, e.g. ";" or "//". Parse sets it to the
+// first prefix given WithCommentPrefixes.
+func (m *Modifier) SetCommentPrefix(prefix string) {
+	if prefix == string(COMMENT) {
+		prefix = ""
+	}
+	m.commentPrefix = prefix
+}
+
+// SetProperty sets k to v, with an inline comment if comment isn't nil,
+// appending k if it is new. It fails without changing anything if k is
+// new and the key validator rejects it, see SetKeyValidator.
+func (m *Modifier) SetProperty(k, v string, comment *string) error {
+	if _, ok := m.index[k]; !ok {
+		if err := m.validateKey(k); err != nil {
+			return err
+		}
+	}
+	m.setProperty(k, v, comment)
+	return nil
+}
+
+// setProperty is SetProperty without validating k.
+func (m *Modifier) setProperty(k, v string, comment *string) {
+	if e, ok := m.index[k]; ok {
+		// modify
+		if e.value != v {
+			e.origin = nil
+		}
+		e.value = v
+		if e.quote == "" && m.quotedValues && needsQuotes(v) {
+			e.quote = string(DOUBLE_QUOTE)
+		}
+		if comment != nil {
+			if !e.hasComment {
+				e.marker = m.commentPrefix
+			}
+			if e.comment != *comment {
+				e.spacing = nil
+			}
+			e.comment = *comment
+			e.hasComment = true
+		}
+		return
+	}
 	prop := Property{
 		key:     k,
 		value:   v,
-		comment: "",
 		lineNum: NO_LINE,
+		marker:  m.commentPrefix,
 	}
-	if p, ok := m.kv[k]; ok {
-		// modify
-		prop.lineNum = p.lineNum
-		if comment == nil {
-			prop.comment = p.comment
-		} else {
-			prop.comment = *comment
-		}
-		m.kv[k] = prop
-		m.props[p.lineNum-1] = prop
-		return
+	if m.quotedValues && needsQuotes(v) {
+		prop.quote = string(DOUBLE_QUOTE)
+	}
+	if comment != nil {
+		prop.comment = *comment
+		prop.hasComment = true
+	}
+	m.add(prop)
+}
+
+// SetComment sets the inline comment of key, keeping its value, like
+// SetProperty with the value it has.
+func (m *Modifier) SetComment(key, comment string) error {
+	e, ok := m.index[key]
+	if !ok {
+		return fmt.Errorf("key %q not found", key)
 	}
-	prop.lineNum = len(m.props) + 1
-	m.props = append(m.props, prop)
-	m.kv[prop.key] = prop
+	m.setProperty(key, e.value, &comment)
+	return nil
+}
+
+// RemoveComment removes the inline comment of key, keeping its value, and
+// reports whether there was one.
+func (m *Modifier) RemoveComment(key string) bool {
+	e, ok := m.index[key]
+	if !ok || !e.hasComment {
+		return false
+	}
+	e.comment, e.hasComment, e.spacing = "", false, nil
+	return true
 }
 
 func (m *Modifier) RemoveProperty(k string) bool {
-	if p, ok := m.kv[k]; ok {
-		delete(m.kv, k)
-		idx := p.lineNum - 1
-		m.props = append(m.props[:idx], m.props[idx+1:]...)
-		return true
+	e, ok := m.index[k]
+	if !ok {
+		return false
 	}
-	return false
+	if m.commentBlocks {
+		for _, c := range m.commentBlock(e) {
+			m.remove(c)
+		}
+	}
+	m.remove(e)
+	return true
+}
+
+// RenameKey renames oldKey to newKey, keeping its value, comment and
+// position. If newKey already exists it fails, unless overwrite is set in
+// which case the existing newKey line is removed.
+func (m *Modifier) RenameKey(oldKey, newKey string, overwrite bool) error {
+	e, ok := m.index[oldKey]
+	if !ok {
+		return fmt.Errorf("key %q not found", oldKey)
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	if err := m.validateKey(newKey); err != nil {
+		return err
+	}
+	if _, ok := m.index[newKey]; ok {
+		if !overwrite {
+			return fmt.Errorf("key %q already exists", newKey)
+		}
+		m.RemoveProperty(newKey)
+	}
+
+	m.unindex(e)
+	e.key = newKey
+	m.index[newKey] = e
+	return nil
+}
+
+// AddComment appends a standalone comment. A text with several lines
+// becomes one comment line per line.
+func (m *Modifier) AddComment(text string) {
+	m.add(m.commentLines(text)...)
+}
+
+// AddBlankLine appends an empty line.
+func (m *Modifier) AddBlankLine() {
+	m.add(Property{})
+}
+
+// InsertComment inserts a standalone comment so that it starts at the
+// 1-based line number at. at may be one past the last line to append.
+func (m *Modifier) InsertComment(at int, text string) error {
+	return m.insertAt(at, m.commentLines(text)...)
+}
+
+// InsertBlankLine inserts an empty line at the 1-based line number at.
+func (m *Modifier) InsertBlankLine(at int) error {
+	return m.insertAt(at, Property{})
+}
+
+// InsertAfter inserts prop right after the line of anchorKey, e.g. to
+// keep a new key next to related ones instead of appending it. A prop
+// with a key fails if the key is already set or the key validator rejects
+// it.
+func (m *Modifier) InsertAfter(anchorKey string, prop Property) error {
+	anchor, err := m.anchor(anchorKey, &prop)
+	if err != nil {
+		return err
+	}
+	m.insertBefore(anchor.next, prop)
+	return nil
+}
+
+// InsertBefore inserts prop above the line of anchorKey and the comment
+// lines directly above it, failing like InsertAfter.
+func (m *Modifier) InsertBefore(anchorKey string, prop Property) error {
+	anchor, err := m.anchor(anchorKey, &prop)
+	if err != nil {
+		return err
+	}
+	mark := anchor
+	if block := m.commentBlock(anchor); len(block) > 0 {
+		mark = block[len(block)-1]
+	}
+	m.insertBefore(mark, prop)
+	return nil
+}
+
+// anchor returns the line of anchorKey to insert prop next to, after
+// checking prop and giving it the comment prefix and quotes of the lines
+// SetProperty adds.
+func (m *Modifier) anchor(anchorKey string, prop *Property) (*entry, error) {
+	anchor, ok := m.index[anchorKey]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found", anchorKey)
+	}
+	if prop.key != "" {
+		if _, ok := m.index[prop.key]; ok {
+			return nil, fmt.Errorf("key %q already exists", prop.key)
+		}
+		if err := m.validateKey(prop.key); err != nil {
+			return nil, err
+		}
+		if prop.quote == "" && m.quotedValues && needsQuotes(prop.value) {
+			prop.quote = string(DOUBLE_QUOTE)
+		}
+	}
+	if prop.hasComment && prop.marker == "" {
+		prop.marker = m.commentPrefix
+	}
+	return anchor, nil
+}
+
+func (m *Modifier) insertAt(at int, props ...Property) error {
+	if at < 1 || at > m.count+1 {
+		return fmt.Errorf("line %d out of range [1, %d]", at, m.count+1)
+	}
+	m.insertBefore(m.at(at), props...)
+	return nil
+}
+
+func (m *Modifier) commentLines(text string) []Property {
+	lines := strings.Split(text, "\n")
+	props := make([]Property, 0, len(lines))
+	for _, line := range lines {
+		props = append(props, Property{
+			comment:    strings.TrimSpace(line),
+			hasComment: true,
+			marker:     m.commentPrefix,
+		})
+	}
+	return props
 }
 
 func (m *Modifier) Text() string {
 	var sb strings.Builder
-	for _, p := range m.props {
-		sb.WriteString(p.String())
+	for e := m.head; e != nil; e = e.next {
+		sb.WriteString(e.text())
 		sb.WriteString("\n")
 	}
 	return sb.String()
 }
 
-func (m *Modifier) Save(w io.Writer) error {
+// SaveOption configures how Save writes the properties.
+type SaveOption func(*saveConfig)
+
+type saveConfig struct {
+	wrapColumn int
+	redact     bool
+	redactWith string
+	unicode    bool
+	latin1     bool
+	bom        bool
+	// lineEnding is set by WithLineEnding, newLines by the Modifier
+	lineEnding string
+	newLines   string
+	// noFinalNewline drops the line ending of the last line
+	noFinalNewline bool
+	// noBlankLines is set by WithoutBlankLines
+	noBlankLines bool
+}
+
+// WithWrap wraps the values of lines longer than column characters
+// using backslash continuations. A column <= 0 disables wrapping.
+func WithWrap(column int) SaveOption {
+	return func(c *saveConfig) {
+		c.wrapColumn = column
+	}
+}
+
+// WithRedaction replaces the values of properties annotated with @secret
+// by placeholder.
+func WithRedaction(placeholder string) SaveOption {
+	return func(c *saveConfig) {
+		c.redact = true
+		c.redactWith = placeholder
+	}
+}
+
+// WithUnicodeEscapes writes the characters of keys and values outside of
+// ASCII as \uXXXX escapes, for java.util.Properties readers expecting
+// ISO-8859-1, see EncodeUnicode.
+func WithUnicodeEscapes() SaveOption {
+	return func(c *saveConfig) {
+		c.unicode = true
+	}
+}
+
+func (m *Modifier) Save(w io.Writer, opts ...SaveOption) error {
+	return save(w, m.lines(), m.saveOptions(opts))
+}
+
+// saveOptions returns opts after the options of the Modifier itself, so
+// that opts override them.
+func (m *Modifier) saveOptions(opts []SaveOption) []SaveOption {
+	return append([]SaveOption{WithEncoding(m.encoding), WithBOM(m.bom), withNewLines(m.LineEnding()), withFinalNewline(m.HasFinalNewline())}, opts...)
+}
+
+// SaveFiltered saves only the properties whose key satisfies keep, each
+// with the comment lines directly above it. Blank lines separating the
+// kept properties in the original file are kept too.
+func (m *Modifier) SaveFiltered(w io.Writer, keep func(key string) bool, opts ...SaveOption) error {
+	var props, pending []Property
+	separated := false
+	for _, p := range m.lines() {
+		switch {
+		case p.IsEmpty():
+			pending = nil
+			separated = true
+		case p.key == "":
+			pending = append(pending, p)
+		default:
+			if keep(p.key) {
+				if separated && len(props) > 0 {
+					props = append(props, Property{})
+				}
+				props = append(props, pending...)
+				props = append(props, p)
+				separated = false
+			}
+			pending = nil
+		}
+	}
+	return save(w, props, m.saveOptions(opts))
+}
+
+func save(w io.Writer, props []Property, opts []SaveOption) error {
+	cfg := saveConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ending := cfg.lineEnding
+	if ending == "" {
+		ending = cfg.newLines
+	}
+	if ending == "" {
+		ending = LINE_ENDING_LF
+	}
+	if cfg.noBlankLines {
+		props = withoutBlankLines(props)
+	}
+
 	buf := bufio.NewWriter(w)
-	for _, p := range m.props {
-		buf.WriteString(p.String())
-		buf.WriteString("\n")
+	write := buf.WriteString
+	if cfg.latin1 {
+		write = func(s string) (int, error) {
+			return buf.WriteString(encodeLatin1(s))
+		}
+	} else if cfg.bom {
+		buf.WriteString(BOM)
+	}
+	for i, p := range props {
+		last := i == len(props)-1 && cfg.noFinalNewline
+		if cfg.redact && p.Annotations().Has(ANNOTATION_SECRET) {
+			p.value = cfg.redactWith
+		}
+		keepRaw := p.unmodified() && (!cfg.unicode || isASCII(p.raw))
+		if cfg.unicode && !keepRaw {
+			p.key = EncodeUnicode(p.key)
+			p.value = EncodeUnicode(p.value)
+		}
+		if keepRaw && cfg.lineEnding == "" {
+			write(p.raw)
+			if !last {
+				buf.WriteString(LINE_ENDING_LF)
+			}
+			continue
+		}
+		var line string
+		if keepRaw {
+			line = p.raw
+		} else if cfg.wrapColumn > 0 {
+			line = wrapProperty(&p, cfg.wrapColumn)
+		} else {
+			line = p.String()
+		}
+		write(convertLineEndings(line, ending))
+		if !last {
+			buf.WriteString(ending)
+		}
 	}
 	return buf.Flush()
 }
@@ -0,0 +1,17 @@
+package gpm_test
+
+import (
+	"gpm/gpmtest"
+	"math/rand"
+	"testing"
+)
+
+func TestOperationsKeepModel(t *testing.T) {
+	for seed := int64(1); seed <= 200; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		input := gpmtest.RandomDocument(r, 20)
+		if err := gpmtest.CheckOperations(input, r, 50); err != nil {
+			t.Fatalf("seed %d: %v\ninput:\n%s", seed, err, input)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package gpm
+
+import (
+	"strings"
+)
+
+// NormalizeOptions controls the whitespace normalization done by
+// Modifier.Normalize and checked by Lint.
+type NormalizeOptions struct {
+	// TabWidth is the number of spaces a tab is converted to. Tabs are
+	// kept as they are when it is 0.
+	TabWidth int
+	// MaxBlankLines is the maximum number of consecutive blank lines,
+	// longer runs are collapsed. A negative value keeps all blank lines.
+	MaxBlankLines int
+}
+
+// DefaultNormalizeOptions keeps tabs and allows up to 2 consecutive blank
+// lines.
+func DefaultNormalizeOptions() NormalizeOptions {
+	return NormalizeOptions{
+		TabWidth:      0,
+		MaxBlankLines: 2,
+	}
+}
+
+// Normalize strips trailing whitespace from values and comments, converts
+// tabs in separators and comments to spaces and collapses runs of blank
+// lines. Tabs inside values are data and are left alone. It returns the
+// number of lines that were changed or removed.
+func (m *Modifier) Normalize(opts NormalizeOptions) int {
+	changed := m.CollapseBlankLines(opts.MaxBlankLines)
+	lines := m.lines()
+	props := lines[:0]
+	for _, p := range lines {
+		if p.IsBlank() {
+			props = append(props, p)
+			continue
+		}
+
+		// normalized lines are rewritten, but values continued over
+		// several lines keep them
+		if p.Lines() == 1 {
+			p.original = nil
+		}
+		n := p
+		n.value = strings.TrimRight(n.value, " \t")
+		n.comment = strings.TrimRight(n.comment, " \t")
+		if opts.TabWidth > 0 {
+			spaces := strings.Repeat(" ", opts.TabWidth)
+			n.separator = strings.ReplaceAll(n.separator, "\t", spaces)
+			n.comment = strings.ReplaceAll(n.comment, "\t", spaces)
+			if n.spacing != nil && strings.Contains(n.spacing.before+n.spacing.after, "\t") {
+				n.spacing = &commentSpacing{
+					before: strings.ReplaceAll(n.spacing.before, "\t", spaces),
+					after:  strings.ReplaceAll(n.spacing.after, "\t", spaces),
+				}
+			}
+		}
+		if n != p {
+			changed++
+		}
+		props = append(props, n)
+	}
+	m.setLines(props)
+	return changed
+}
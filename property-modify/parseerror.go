@@ -0,0 +1,133 @@
+package gpm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ERRORS_IGNORE reads malformed lines as well as it can, the default
+	ERRORS_IGNORE = "ignore"
+	// ERRORS_STRICT stops parsing at the first malformed line
+	ERRORS_STRICT = "strict"
+	// ERRORS_COLLECT parses the whole input and reports every malformed
+	// line
+	ERRORS_COLLECT = "collect"
+)
+
+// ParseError is a malformed line.
+type ParseError struct {
+	Line int
+	// Column counts characters from 1, in the logical line for a line
+	// continued with backslashes
+	Column int
+	Raw    string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s: %q", e.Line, e.Column, e.Reason, e.Raw)
+}
+
+// ParseErrors are the malformed lines found WithErrors(ERRORS_COLLECT),
+// in file order.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap lets errors.As find every *ParseError.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// WithErrors sets what the parser does with malformed lines: lines
+// without a separator, with an empty key or whitespace in the key, a
+// backslash continuing the last line, and, WithUnicodeDecoding, broken
+// \uXXXX escapes. By default, ERRORS_IGNORE, they are read as well as
+// possible. ERRORS_STRICT returns a *ParseError for the first one, and
+// ERRORS_COLLECT returns ParseErrors with all of them once the whole
+// input is read.
+func WithErrors(mode string) ParserOption {
+	return func(p *Parser) {
+		p.errorMode = mode
+	}
+}
+
+// Errors returns the malformed lines found by the last parse
+// WithErrors(ERRORS_COLLECT).
+func (p *Parser) Errors() []*ParseError {
+	return p.errors
+}
+
+// check returns the error of the logical line parsed as prop, nil if it
+// is well-formed. last is set for a line continued at the end of the
+// input.
+func (p *Parser) check(prop *Property, line rawLine, raw string, last bool) *ParseError {
+	indent := len([]rune(raw)) - len([]rune(strings.TrimLeft(raw, " \t\f")))
+	fail := func(at int, reason string) *ParseError {
+		return &ParseError{Line: prop.lineNum, Column: indent + at + 1, Raw: raw, Reason: reason}
+	}
+	if last {
+		return fail(len(line), "backslash continues the last line")
+	}
+	if len(line) == 0 || p.commentAt(line, 0) != "" {
+		return nil
+	}
+	if prop.key == "" {
+		if prop.separator == "" {
+			return fail(0, "missing '=' after the key")
+		}
+		return fail(indexRune(line, strings.TrimSpace(prop.separator)), "empty key")
+	}
+	if !p.javaSeparators {
+		escaped := false
+		for i, r := range []rune(prop.key) {
+			switch {
+			case escaped:
+				escaped = false
+			case r == ESCAPE:
+				escaped = true
+			case isBlank(r) || r == '\f':
+				return fail(i, "whitespace in the key")
+			}
+		}
+	}
+	if p.decodeUnicode {
+		escaped := false
+		for i, r := range line {
+			switch {
+			case escaped:
+				escaped = false
+				if _, ok := hexCode(line, i+1); r == 'u' && !ok {
+					return fail(i-1, `malformed \uXXXX escape`)
+				}
+			case r == ESCAPE:
+				escaped = true
+			case p.commentAt(line, i) != "":
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// indexRune returns the index of the first rune of s in line, 0 if there
+// is none.
+func indexRune(line rawLine, s string) int {
+	for i, c := range line {
+		if s != "" && c == []rune(s)[0] {
+			return i
+		}
+	}
+	return 0
+}
@@ -2,6 +2,7 @@ package gpm
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -9,16 +10,105 @@ import (
 
 const (
 	COMMENT = '#'
+	// BANG starts a comment line too, but not an inline comment
+	BANG    = '!'
 	EQUALS  = '='
+	COLON   = ':'
+	ESCAPE  = '\\'
 	NO_LINE = -1
 )
 
 type rawLine []rune
 
 // Parser represents a parser for a specific format of property files.
+// Most code should use Parse, which returns a Document ready to modify.
 type Parser struct {
-	lines []rawLine
 	props []Property
+	// crlf and lf count the lines ending with "\r\n" and "\n"
+	crlf, lf int
+	// javaSeparators is set by WithJavaSeparators
+	javaSeparators bool
+	// decodeUnicode is set by WithUnicodeDecoding
+	decodeUnicode bool
+	// commentPrefixes are set by WithCommentPrefixes, nil for '#' and '!'
+	commentPrefixes []string
+	// encoding is set by WithDecoding, and to the detected encoding by
+	// Parse
+	encoding string
+	// bom is set if the input started with a BOM
+	bom bool
+	// noFinalNewline is set if the last line had no line ending
+	noFinalNewline bool
+	// errorMode is set by WithErrors, errors are the malformed lines
+	// found with ERRORS_COLLECT
+	errorMode string
+	errors    []*ParseError
+	// duplicatePolicy is set by WithDuplicates, keyLines are the lines of
+	// every key, keys in the order they are first set
+	duplicatePolicy string
+	keyLines        map[string][]int
+	keys            []string
+	// commentBlocks is set by WithCommentBlocks
+	commentBlocks bool
+	// keyValidator is set by WithKeyValidator, see validator
+	keyValidator    KeyValidator
+	keyValidatorSet bool
+	// quotedValues is set by WithQuotedValues
+	quotedValues bool
+	// usage is set by WithUsage
+	usage *Usage
+	// javaWhitespace is set by WithJavaWhitespace
+	javaWhitespace bool
+}
+
+// ParserOption configures a Parser.
+type ParserOption func(*Parser)
+
+// WithJavaSeparators makes the parser end keys at the first ':' or
+// whitespace too, like java.util.Properties does: "key: value" and
+// "key value" are the property key. The separator is kept as it is on
+// save.
+func WithJavaSeparators() ParserOption {
+	return func(p *Parser) {
+		p.javaSeparators = true
+	}
+}
+
+// WithJavaWhitespace keeps the whitespace at the end of values, like
+// java.util.Properties does: only the whitespace before a value is
+// skipped, so "key=value  " is the value "value  ". The whitespace before
+// an inline comment is still dropped. Note that Lint reports the trailing
+// whitespace and Normalize strips it.
+func WithJavaWhitespace() ParserOption {
+	return func(p *Parser) {
+		p.javaWhitespace = true
+	}
+}
+
+// WithUnicodeDecoding decodes the \uXXXX escapes of keys and values, see
+// DecodeUnicode. Save them WithUnicodeEscapes to write them back as
+// escapes.
+func WithUnicodeDecoding() ParserOption {
+	return func(p *Parser) {
+		p.decodeUnicode = true
+	}
+}
+
+// WithCommentPrefixes sets the prefixes starting comments, e.g. ";" and
+// "//" for INI-like files, instead of '#' and, at the start of a line,
+// '!'. Like in INI files, a prefix only starts an inline comment after
+// whitespace, so that "url=http://host" keeps its value, and it can be
+// escaped with a backslash. The first prefix is the one a Document uses
+// for the comments it adds.
+func WithCommentPrefixes(prefixes ...string) ParserOption {
+	return func(p *Parser) {
+		p.commentPrefixes = nil
+		for _, prefix := range prefixes {
+			if prefix != "" {
+				p.commentPrefixes = append(p.commentPrefixes, prefix)
+			}
+		}
+	}
 }
 
 type Property struct {
@@ -26,35 +116,232 @@ type Property struct {
 	value      string
 	comment    string
 	hasComment bool
-	lineNum    int
+	// tightComment is set for comment-only lines without a space after
+	// the '#', like the ones Properties.store() writes.
+	tightComment bool
+	// marker is the prefix of the comment, "" for '#'
+	marker  string
+	lineNum int
+	// separator is the exact text between key and value found on parse,
+	// including surrounding whitespace, e.g. "=", " = " or "= ".
+	separator string
+	// doc holds the comment lines directly above the property, one per
+	// line.
+	doc string
+	// raw is the line as it was read and offset the byte offset of its
+	// start in the input.
+	raw    string
+	offset int
+	// original is set by ParsePreserving to the line as parsed: the line
+	// is saved as raw as long as it is not modified.
+	original *Property
+	// origin is set by SetPropertyFrom to where the value was copied from
+	origin *Provenance
+	// verbatim is the text of a line that is neither a property nor a
+	// comment, written back as it was read
+	verbatim string
+	// quote is the quote around the value read WithQuotedValues, "" for
+	// none
+	quote string
+	// spacing is the whitespace around the prefix of a parsed inline
+	// comment, nil to write single spaces
+	spacing *commentSpacing
+	// prefixes are the comment prefixes of the file, separated by '\n',
+	// "" for '#' and '!'. They are escaped in the key and value.
+	prefixes string
+}
+
+// commentSpacing is the whitespace before and after the prefix of an
+// inline comment, e.g. "value    ## note" has "    " before the '#' and
+// "" after it, the comment being "# note".
+type commentSpacing struct {
+	before, after string
 }
 
 func (p *Property) String() string {
+	if p.IsRaw() {
+		return p.verbatim
+	}
 	if p.IsEmpty() {
 		return ""
 	}
 
+	marker := p.CommentMarker()
 	if p.IsCommentOnly() {
 		if p.comment == "" {
-			return "#"
+			return marker
 		}
-		if p.comment[0] == COMMENT {
-			return "#" + p.comment
+		if strings.HasPrefix(p.comment, marker) || p.tightComment {
+			return marker + p.comment
 		}
-		return fmt.Sprintf("# %s", p.comment)
+		return fmt.Sprintf("%s %s", marker, p.comment)
 	}
 
-	if p.hasComment {
-		if p.comment == "" {
-			return fmt.Sprintf("%s=%s #", p.key, p.value)
-		}
-		if p.comment[0] == COMMENT {
-			return fmt.Sprintf("%s=%s #%s", p.key, p.value, p.comment)
-		}
-		return fmt.Sprintf("%s=%s # %s", p.key, p.value, p.comment)
+	head, value, tail := p.parts()
+	return head + value + tail
+}
+
+// parts splits the line of a property into the key and separator, the
+// escaped value and the inline comment after it, as String writes them.
+func (p *Property) parts() (head, value, tail string) {
+	key, sep, value := p.escapedKey(), p.Separator(), p.escapedValue()
+	if p.hasComment && value == "" {
+		// the whitespace ending the separator of a key without value is
+		// the whitespace before the comment, written below
+		sep = strings.TrimRight(sep, javaBlanks)
+	}
+	head = key + sep
+	if !p.hasComment {
+		return head, value, ""
+	}
+	marker := p.CommentMarker()
+	switch {
+	case p.spacing != nil:
+		tail = p.spacing.before + marker + p.spacing.after + p.comment
+	case p.comment == "":
+		tail = " " + marker
+	case strings.HasPrefix(p.comment, marker):
+		tail = " " + marker + p.comment
+	default:
+		tail = " " + marker + " " + p.comment
+	}
+	return head, value, tail
+}
+
+// escapedKey returns the key with a backslash before any '=' or comment
+// prefix, and a leading '!' if the prefixes are '#' and '!', that doesn't
+// have one yet, so that it is read back as the key.
+func (p *Property) escapedKey() string {
+	key := p.escapeComments(escapeUnescaped(p.key, "="))
+	if p.prefixes == "" && strings.HasPrefix(key, string(BANG)) {
+		return string(ESCAPE) + key
+	}
+	return key
+}
+
+// escapedValue returns the value with a backslash before any comment
+// prefix that doesn't have one yet, e.g. "#FF0000" set by SetProperty, so
+// that it isn't read back as a comment.
+func (p *Property) escapedValue() string {
+	return p.quote + p.escapeComments(p.value) + p.quote
+}
+
+// escapeComments escapes the comment prefixes of the file in s, see
+// escapeComments.
+func (p *Property) escapeComments(s string) string {
+	if p.prefixes == "" {
+		return escapeComments(s, string(COMMENT))
+	}
+	for _, prefix := range strings.Split(p.prefixes, "\n") {
+		s = escapeComments(s, prefix)
+	}
+	return s
+}
+
+// text returns the line to save: the raw line of a preserved line that
+// is not modified, String otherwise.
+func (p *Property) text() string {
+	if p.unmodified() {
+		return p.raw
+	}
+	return p.String()
+}
+
+// unmodified reports whether p is a line parsed by ParsePreserving that
+// still has the content it was parsed with.
+func (p *Property) unmodified() bool {
+	o := p.original
+	return o != nil &&
+		o.key == p.key &&
+		o.value == p.value &&
+		o.comment == p.comment &&
+		o.hasComment == p.hasComment &&
+		o.tightComment == p.tightComment &&
+		o.marker == p.marker &&
+		o.separator == p.separator &&
+		o.quote == p.quote &&
+		o.spacing == p.spacing
+}
+
+// NewProperty returns the line key=value, with an inline comment if
+// comment isn't nil, for NewModifier or Modifier methods taking lines.
+func NewProperty(key, value string, comment *string) Property {
+	p := Property{key: key, value: value, lineNum: NO_LINE}
+	if comment != nil {
+		p.comment = *comment
+		p.hasComment = true
+	}
+	return p
+}
+
+// NewComment returns a comment-only line of text, which must be a single
+// line.
+func NewComment(text string) Property {
+	return Property{comment: strings.TrimSpace(text), hasComment: true, lineNum: NO_LINE}
+}
+
+// NewBlank returns an empty line.
+func NewBlank() Property {
+	return Property{lineNum: NO_LINE}
+}
+
+// Separator returns the separator between key and value, "=" if the
+// property was not parsed from a file.
+func (p *Property) Separator() string {
+	if p.separator == "" {
+		return string(EQUALS)
+	}
+	return p.separator
+}
+
+// Key returns the key, "" for comment-only and empty lines.
+func (p *Property) Key() string {
+	return p.key
+}
+
+// Value returns the value.
+func (p *Property) Value() string {
+	return p.value
+}
+
+// Comment returns the inline comment, or the text of a comment-only line,
+// without the leading '#'.
+func (p *Property) Comment() string {
+	return p.comment
+}
+
+// HasComment reports whether the line has a comment, which may be empty
+// like in "key=value #".
+func (p *Property) HasComment() bool {
+	return p.hasComment
+}
+
+// CommentMarker returns the prefix of the comment: "#", "!" or one set
+// by WithCommentPrefixes.
+func (p *Property) CommentMarker() string {
+	if p.marker == "" {
+		return string(COMMENT)
 	}
+	return p.marker
+}
+
+// Doc returns the comment lines directly above the property, one per
+// line.
+func (p *Property) Doc() string {
+	return p.doc
+}
+
+// LineNum returns the 1-based line number, NO_LINE for properties that
+// are not in a file yet.
+func (p *Property) LineNum() int {
+	return p.lineNum
+}
 
-	return fmt.Sprintf("%s=%s", p.key, p.value)
+// Lines returns the number of lines of a parsed property, more than 1 when
+// its value is continued with trailing backslashes. Value returns the
+// joined value. A Modifier numbers the property as a single line.
+func (p *Property) Lines() int {
+	return strings.Count(p.raw, "\n") + 1
 }
 
 func (p *Property) IsCommentOnly() bool {
@@ -62,79 +349,443 @@ func (p *Property) IsCommentOnly() bool {
 }
 
 func (p *Property) IsEmpty() bool {
-	return p.key == "" && !p.hasComment
+	return p.key == "" && !p.hasComment && p.verbatim == ""
+}
+
+// IsRaw reports whether p is a line that is neither a property nor a
+// comment, like "=value" or a shell command pasted without a '=', kept
+// verbatim.
+func (p *Property) IsRaw() bool {
+	return p.verbatim != ""
 }
 
 // NewParser creates a new Parser instance.
-func NewParser() *Parser {
-	return &Parser{}
+func NewParser(opts ...ParserOption) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (p *Parser) Parse(r io.Reader) error {
-	buf := bufio.NewScanner(r)
-	p.lines = make([]rawLine, 0, 64)
+	return p.parse(r, false)
+}
+
+// ParsePreserving parses like Parse, but keeps the layout of the lines:
+// indentation, whitespace around separators and comments, and line
+// endings. Saving rewrites only the lines that were modified, the others
+// are written back byte-identical.
+func (p *Parser) ParsePreserving(r io.Reader) error {
+	return p.parse(r, true)
+}
+
+func (p *Parser) parse(r io.Reader, preserve bool) error {
+	p.props = make([]Property, 0, 64)
+	err := p.scan(r, preserve, func(prop Property) error {
+		p.props = append(p.props, prop)
+		return nil
+	})
+	if err == nil && p.duplicatePolicy == DUPLICATES_KEEP_LAST {
+		p.keepLast()
+	}
+	return err
+}
+
+// ParseFunc parses like Parse, but calls fn with every line as soon as it
+// is read instead of keeping them, so that huge generated files can be
+// processed in constant memory, except with ENCODING_AUTO which reads
+// the whole input to detect its encoding. It stops at the first error of
+// fn and returns it. GetProps returns nothing afterwards.
+func (p *Parser) ParseFunc(r io.Reader, fn func(Property) error) error {
+	p.props = nil
+	return p.scan(r, false, fn)
+}
+
+// scan reads r line by line, joining continued lines, and calls fn with
+// every parsed line.
+func (p *Parser) scan(r io.Reader, preserve bool, fn func(Property) error) error {
+	r, err := p.decode(r)
+	if err != nil {
+		return err
+	}
+	p.crlf, p.lf = 0, 0
+	p.errors = nil
+	p.keyLines, p.keys = make(map[string][]int), nil
+	tail := &tailReader{r: r}
+	buf := bufio.NewScanner(tail)
+	buf.Split(scanRawLines)
+
+	var doc []string
+	var line rawLine
+	// raws are the physical lines of line, start the number of the first
+	var raws []string
+	start, lineNum, offset := 0, 0, 0
+	continued := false
+	// last is set for a line continued at the end of the input
+	emit := func(last bool) error {
+		prop := p.parseTokens(line, start)
+		var malformed *ParseError
+		if p.errorMode == ERRORS_STRICT || p.errorMode == ERRORS_COLLECT {
+			malformed = p.check(&prop, line, raws[0], last)
+			if malformed == nil {
+				malformed = p.validateKey(&prop, raws[0])
+			}
+		}
+		if malformed != nil {
+			if p.errorMode == ERRORS_STRICT {
+				return malformed
+			}
+			p.errors = append(p.errors, malformed)
+		}
+		if prop.key == "" && len(line) > 0 && p.commentAt(line, 0) == "" {
+			verbatim := make([]string, len(raws))
+			for i, raw := range raws {
+				verbatim[i] = strings.TrimSuffix(raw, "\r")
+			}
+			prop = Property{lineNum: start, verbatim: strings.Join(verbatim, "\n")}
+		}
+		if p.decodeUnicode {
+			prop.key = DecodeUnicode(prop.key)
+			prop.value = DecodeUnicode(prop.value)
+		}
+		duplicate, err := p.duplicate(&prop, raws[0])
+		if err != nil {
+			if p.errorMode == ERRORS_STRICT {
+				return err
+			}
+			p.errors = append(p.errors, err)
+		}
+		prop.raw = strings.Join(raws, "\n")
+		prop.offset = offset
+		offset += len(prop.raw) + 1
+		switch {
+		case prop.IsCommentOnly() && p.commentBlocks && prop.IsDisabled():
+			// a commented-out property ends a comment block
+			doc = doc[:0]
+		case prop.IsCommentOnly():
+			doc = append(doc, prop.comment)
+		case prop.IsEmpty(), prop.IsRaw():
+			doc = doc[:0]
+		default:
+			prop.doc = strings.Join(doc, "\n")
+			doc = doc[:0]
+		}
+		if preserve || continued {
+			// continued lines are kept until they are modified
+			original := prop
+			prop.original = &original
+		}
+		line, raws, continued = nil, nil, false
+		if duplicate && p.duplicatePolicy == DUPLICATES_KEEP_FIRST {
+			return nil
+		}
+		return fn(prop)
+	}
 	for buf.Scan() {
 		rLine := buf.Text()
-		runes := rawLine(strings.TrimSpace(rLine))
-		p.lines = append(p.lines, runes)
-
+		lineNum++
+		if strings.HasSuffix(rLine, "\r") {
+			p.crlf++
+		} else {
+			p.lf++
+		}
+		trimmed := strings.TrimSpace(rLine)
+		if p.javaWhitespace {
+			trimmed = strings.TrimLeft(strings.TrimSuffix(rLine, "\r"), javaBlanks)
+		}
+		runes := rawLine(trimmed)
+		if raws == nil {
+			start = lineNum
+		}
+		// a continuation line is joined without its leading whitespace
+		line = append(line, runes...)
+		raws = append(raws, rLine)
+		if p.continues(line) {
+			line = line[: len(line)-1 : len(line)-1]
+			continued = true
+			continue
+		}
+		if err := emit(false); err != nil {
+			return err
+		}
 	}
 	if err := buf.Err(); err != nil {
 		return err
 	}
-
-	p.props = make([]Property, 0, len(p.lines))
-	for i, line := range p.lines {
-		prop := p.parseTokens(line, i)
-		p.props = append(p.props, prop)
+	if raws != nil {
+		// the last line ends with a backslash
+		if err := emit(true); err != nil {
+			return err
+		}
+	}
+	p.noFinalNewline = tail.read && tail.last != '\n'
+	if p.noFinalNewline && tail.last != '\r' {
+		// the last line has no line ending at all
+		p.lf--
+	}
+	if len(p.errors) > 0 {
+		return ParseErrors(p.errors)
 	}
 	return nil
 }
 
+// continues reports whether line goes on on the next line: it ends with
+// a backslash that is not escaped and is not a comment.
+func (p *Parser) continues(line rawLine) bool {
+	if len(line) == 0 || p.commentAt(line, 0) != "" {
+		return false
+	}
+	backslashes := 0
+	for i := len(line) - 1; i >= 0 && line[i] == ESCAPE; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
+
+// scanRawLines is bufio.ScanLines without dropping a '\r' before the
+// '\n'.
+func scanRawLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 func (p *Parser) parseTokens(pureLine rawLine, lineNum int) Property {
-	var key, value, comment string
-	var hasComment bool
+	var key, value, comment, separator string
+	var hasComment, tightComment bool
+	var spacing *commentSpacing
 	var valueEndAt int = -1
 	var firstEqAt int = -1
 
+	var marker string
+	if len(pureLine) > 0 && p.commentPrefixes == nil && pureLine[0] == BANG {
+		return Property{
+			comment:      strings.TrimSpace(string(pureLine[1:])),
+			hasComment:   true,
+			tightComment: len(pureLine) > 1 && !isBlank(pureLine[1]),
+			marker:       string(BANG),
+			lineNum:      lineNum,
+		}
+	}
+
+	escaped := false
+	// blankSeparator is set after a whitespace separator, until the value
+	// or a ':' or '=' ending the separator
+	blankSeparator := false
 	for i, r := range pureLine {
-		if r == COMMENT {
-			if i != len(pureLine)-1 {
-				comment = string(pureLine[i+1:])
-				comment = strings.TrimSpace(comment)
-			}
+		if escaped || r == ESCAPE {
+			// an escaped '#' or '=' belongs to the key or value
+			escaped = !escaped
+			blankSeparator = false
+			valueEndAt = i
+			continue
+		}
+		if marker = p.commentAt(pureLine, i); marker != "" {
+			rest := pureLine[i+len([]rune(marker)):]
+			comment = strings.TrimSpace(string(rest))
 			hasComment = true
+			tightComment = key == "" && i == 0 && len(rest) > 0 && !isBlank(rest[0])
+			if i > 0 {
+				before := string(pureLine[:i])
+				spacing = &commentSpacing{
+					before: before[len(strings.TrimRight(before, " \t")):],
+					after:  string(rest[:len(rest)-len([]rune(strings.TrimLeft(string(rest), " \t")))]),
+				}
+			}
 			valueEndAt = i - 1
 			break
 		}
-		if r == EQUALS {
+		if p.isSeparator(r) {
 			if firstEqAt != -1 {
 				// do nothing
 			} else {
 				firstEqAt = i
 				key = string(pureLine[:i])
 				key = strings.TrimSpace(key)
+				blankSeparator = isBlank(r)
+				continue
+			}
+		}
+		if blankSeparator {
+			if isBlank(r) {
+				continue
+			}
+			blankSeparator = false
+			if r == EQUALS || r == COLON {
+				firstEqAt = i
 				continue
 			}
 		}
 		valueEndAt = i
 	}
+	if firstEqAt == -1 && p.javaSeparators && valueEndAt != -1 {
+		// a key without value
+		key = strings.TrimSpace(string(pureLine[:valueEndAt+1]))
+	}
 	if valueEndAt != -1 {
-		if firstEqAt == -1 {
+		if firstEqAt == -1 || valueEndAt <= firstEqAt {
 			// do nothing
 		} else {
 			value = string(pureLine[firstEqAt+1 : valueEndAt+1])
-			value = strings.TrimSpace(value)
+			if p.javaWhitespace && !hasComment {
+				value = strings.TrimLeft(value, javaBlanks)
+			} else {
+				value = strings.TrimSpace(value)
+			}
 		}
 	}
+	var quote string
+	if p.quotedValues && firstEqAt != -1 {
+		value, quote = unquote(value)
+	}
+	if firstEqAt != -1 {
+		separator = parseSeparator(pureLine, firstEqAt, valueEndAt)
+	}
+	if marker == string(COMMENT) {
+		marker = ""
+	}
 
 	return Property{
-		key:        key,
-		value:      value,
-		comment:    comment,
-		hasComment: hasComment,
-		lineNum:    lineNum,
+		key:          p.unescapeKey(key),
+		value:        p.unescapeComments(value),
+		comment:      comment,
+		hasComment:   hasComment,
+		tightComment: tightComment,
+		marker:       marker,
+		lineNum:      lineNum,
+		separator:    separator,
+		quote:        quote,
+		spacing:      spacing,
+		prefixes:     p.prefixes(),
+	}
+}
+
+// commentAt returns the comment prefix starting at line[i], "" if there is
+// none.
+func (p *Parser) commentAt(line rawLine, i int) string {
+	if p.commentPrefixes == nil {
+		if line[i] == COMMENT || (i == 0 && line[i] == BANG) {
+			return string(line[i])
+		}
+		return ""
+	}
+	if i > 0 && !isBlank(line[i-1]) {
+		return ""
+	}
+	for _, prefix := range p.commentPrefixes {
+		if strings.HasPrefix(string(line[i:]), prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// unescapeComments drops the backslash before the comment prefixes of a
+// parsed value, which String puts back on save, so that "color=\#FF0000"
+// reads "#FF0000". The other escapes are kept.
+func (p *Parser) unescapeComments(s string) string {
+	if !strings.ContainsRune(s, ESCAPE) {
+		return s
+	}
+	var sb strings.Builder
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped && p.prefixAt(s[i:]):
+			sb.WriteRune(r)
+		case escaped:
+			sb.WriteRune(ESCAPE)
+			sb.WriteRune(r)
+		case r != ESCAPE:
+			sb.WriteRune(r)
+		}
+		escaped = !escaped && r == ESCAPE
+	}
+	if escaped {
+		sb.WriteRune(ESCAPE)
+	}
+	return sb.String()
+}
+
+// prefixAt reports whether s starts with a prefix escaped in keys and
+// values: '#', or one of the comment prefixes.
+func (p *Parser) prefixAt(s string) bool {
+	if p.commentPrefixes == nil {
+		return s[0] == COMMENT
 	}
+	for _, prefix := range p.commentPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeKey is unescapeComments for a key, which also drops the
+// backslash of a leading "\!" that escapedKey puts back.
+func (p *Parser) unescapeKey(key string) string {
+	if p.commentPrefixes == nil && strings.HasPrefix(key, `\!`) {
+		key = key[1:]
+	}
+	return p.unescapeComments(key)
+}
+
+// prefixes returns the comment prefixes for Property.prefixes.
+func (p *Parser) prefixes() string {
+	return strings.Join(p.commentPrefixes, "\n")
+}
+
+// commentPrefix returns the prefix of the comments added to the parsed
+// document.
+func (p *Parser) commentPrefix() string {
+	if p.commentPrefixes == nil {
+		return string(COMMENT)
+	}
+	return p.commentPrefixes[0]
+}
+
+// isSeparator reports whether r ends a key.
+func (p *Parser) isSeparator(r rune) bool {
+	if r == EQUALS {
+		return true
+	}
+	return p.javaSeparators && (r == COLON || isBlank(r))
+}
+
+// parseSeparator returns the '=' or ':' at eqAt together with the
+// whitespace around it, or the whitespace around eqAt when it is blank.
+// Whitespace after '=' only belongs to the separator when a value follows
+// it.
+func parseSeparator(pureLine rawLine, eqAt, valueEndAt int) string {
+	start := eqAt
+	for start > 0 && isBlank(pureLine[start-1]) {
+		start--
+	}
+	end := eqAt + 1
+	for end <= valueEndAt && isBlank(pureLine[end]) {
+		end++
+	}
+	if end > valueEndAt {
+		// no value, drop the trailing whitespace
+		end = eqAt + 1
+	}
+	return string(pureLine[start:end])
+}
+
+// javaBlanks are the characters java.util.Properties skips before keys
+// and values.
+const javaBlanks = " \t\f"
+
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t'
 }
 
 func (p *Parser) GetProps() []Property {
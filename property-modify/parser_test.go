@@ -0,0 +1,127 @@
+package gpm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// saveAndParse saves doc and parses the saved file with opts.
+func saveAndParse(t *testing.T, doc *Document, opts ...ParserOption) (string, *Document) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := doc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ParseString(buf.String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), reparsed
+}
+
+func TestEscapedCommentRoundTrip(t *testing.T) {
+	doc, err := ParseString("color=\\#FF0000\n\\!bang=1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := doc.Get("color"); got != "#FF0000" {
+		t.Errorf("Get(color) = %q, want %q", got, "#FF0000")
+	}
+	if !doc.Has("!bang") {
+		t.Errorf("key !bang not found in %v", doc.Keys())
+	}
+	if err := doc.SetProperty("k", "#FF", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, reparsed := saveAndParse(t, doc)
+	want := "color=\\#FF0000\n\\!bang=1\nk=\\#FF\n"
+	if saved != want {
+		t.Errorf("saved %q, want %q", saved, want)
+	}
+	for key, value := range map[string]string{"color": "#FF0000", "!bang": "1", "k": "#FF"} {
+		if got, ok := reparsed.Get(key); !ok || got != value {
+			t.Errorf("reparsed Get(%s) = %q, %v, want %q", key, got, ok, value)
+		}
+	}
+}
+
+func TestKeyOnlyCommentSaveIsStable(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []SaveOption
+		input string
+		want  string
+	}{
+		{"plain", nil, "flag # note\nother\t# tab\n", "flag # changed\nother\t# tab\n"},
+		{"wrapped", []SaveOption{WithWrap(3)}, "flag # note\nother\t# tab\n", "flag # changed\nother\t# tab\n"},
+		{"wrapped value", []SaveOption{WithWrap(12)}, "flag # note\nk v1 v2 v3 v4   # c\n", "flag # changed\nk v1 v2 v3 \\\n    v4   # c\n"},
+		{"emptied value", nil, "flag = 1   # note\n", "flag = # changed\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			for i := 0; i < 3; i++ {
+				doc, err := ParseString(input, WithJavaSeparators())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := doc.SetComment("flag", "changed"); err != nil {
+					t.Fatal(err)
+				}
+				if strings.HasPrefix(tt.name, "emptied") {
+					if err := doc.SetProperty("flag", "", nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+				var buf bytes.Buffer
+				if err := doc.Save(&buf, tt.opts...); err != nil {
+					t.Fatal(err)
+				}
+				input = buf.String()
+				if input != tt.want {
+					t.Errorf("save %d = %q, want %q", i+1, input, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomCommentPrefixesRoundTrip(t *testing.T) {
+	opts := []ParserOption{WithCommentPrefixes(";", "//")}
+	doc, err := ParseString("\\;lead = 1 \\// not a comment ; comment\n", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := doc.Get(";lead"); got != "1 // not a comment" {
+		t.Errorf("Get(;lead) = %q, want %q", got, "1 // not a comment")
+	}
+	values := map[string]string{
+		";lead": "1 // not a comment",
+		"!bang": "1",
+		"semi":  ";x",
+		"url":   "http://host a //b",
+		"color": "#FF",
+	}
+	for _, key := range []string{"!bang", "semi", "url", "color"} {
+		if err := doc.SetProperty(key, values[key], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved, reparsed := saveAndParse(t, doc, opts...)
+	want := "\\;lead = 1 \\// not a comment ; comment\n" +
+		"!bang=1\n" +
+		"semi=\\;x\n" +
+		"url=http://host a \\//b\n" +
+		"color=#FF\n"
+	if saved != want {
+		t.Errorf("saved %q, want %q", saved, want)
+	}
+	for key, value := range values {
+		if got, ok := reparsed.Get(key); !ok || got != value {
+			t.Errorf("reparsed Get(%s) = %q, %v, want %q", key, got, ok, value)
+		}
+	}
+}
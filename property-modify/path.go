@@ -0,0 +1,116 @@
+package gpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EscapePath returns path as a property value, escaped the way Android
+// Studio writes sdk.dir: "C:\Users\me\sdk" becomes "C\:\\Users\\me\\sdk".
+func EscapePath(path string) string {
+	return EscapeJava(path, false)
+}
+
+// UnescapePath returns the native path of a property value written by
+// EscapePath or by hand, with '/' replaced by the separator of the OS.
+func UnescapePath(value string) (string, error) {
+	path, err := UnescapeJava(value)
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// SetBaseDir makes GetPath and CheckPath resolve relative paths against
+// dir, the directory of the property file, instead of the working
+// directory. Load sets it.
+func (m *Modifier) SetBaseDir(dir string) {
+	m.baseDir = dir
+}
+
+// BaseDir returns the directory relative paths are resolved against, ""
+// for the working directory.
+func (m *Modifier) BaseDir() string {
+	return m.baseDir
+}
+
+// GetPath returns the value of key as a native path, see UnescapePath,
+// resolved against the base directory if it is relative.
+func (m *Modifier) GetPath(key string) (string, bool, error) {
+	value, ok := m.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	path, err := UnescapePath(value)
+	if err != nil {
+		return "", true, fmt.Errorf("%s: %w", key, err)
+	}
+	if m.baseDir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(m.baseDir, path)
+	}
+	return path, true, nil
+}
+
+// SetPath sets key to path, escaped by EscapePath.
+func (m *Modifier) SetPath(key, path string) error {
+	return m.SetProperty(key, EscapePath(path), nil)
+}
+
+// MakeAbsolute rewrites the path of key as an absolute path.
+func (m *Modifier) MakeAbsolute(key string) error {
+	path, ok, err := m.GetPath(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not set", key)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return m.SetPath(key, abs)
+}
+
+// MakeRelative rewrites the path of key relative to the base directory,
+// with '/' separators so that it works on every OS, e.g. once the
+// repository is checked out on another machine.
+func (m *Modifier) MakeRelative(key string) error {
+	path, ok, err := m.GetPath(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not set", key)
+	}
+	base, err := filepath.Abs(m.baseDir)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return m.SetPath(key, filepath.ToSlash(rel))
+}
+
+// CheckPath returns an error if key is not set or the path it holds
+// doesn't exist, e.g. a sdk.dir copied from another machine.
+func (m *Modifier) CheckPath(key string) error {
+	path, ok, err := m.GetPath(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not set", key)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package gpm
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderRe matches MessageFormat placeholders like {0} or
+// {1,number,#} and printf verbs like %s, %1$d or %.2f. A blank after "%"
+// is not taken as a flag, so that "50% off" has no placeholder.
+var placeholderRe = regexp.MustCompile(`\{(\d+)(?:,[^{}]*)?\}|%(?:\d+\$)?[-#+0,(]*\d*(?:\.\d+)?[bBhHsScCdoxXeEfgGaAtTn%]`)
+
+// Placeholders returns the format placeholders of value, sorted. The
+// format details of MessageFormat placeholders are dropped so that
+// {0,number} and {0} compare equal; "%%" is not a placeholder.
+func Placeholders(value string) []string {
+	var found []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(value, -1) {
+		switch {
+		case m[1] != "":
+			found = append(found, "{"+m[1]+"}")
+		case m[0] != "%%":
+			found = append(found, m[0])
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// PlaceholderMismatch is a translated value whose placeholders differ
+// from the base value.
+type PlaceholderMismatch struct {
+	Locale   string
+	Key      string
+	Line     int
+	Expected []string
+	Found    []string
+}
+
+// CheckPlaceholders compares the placeholders of every translated value
+// with the ones of the base value.
+func (b *Bundle) CheckPlaceholders() []PlaceholderMismatch {
+	var mismatches []PlaceholderMismatch
+	for _, l := range b.Locales {
+		for _, p := range l.Modifier.lines() {
+			base, ok := b.Base.index[p.key]
+			if p.key == "" || !ok {
+				continue
+			}
+			expected := Placeholders(base.value)
+			found := Placeholders(p.value)
+			if strings.Join(expected, " ") != strings.Join(found, " ") {
+				mismatches = append(mismatches, PlaceholderMismatch{
+					Locale:   l.Locale,
+					Key:      p.key,
+					Line:     p.lineNum,
+					Expected: expected,
+					Found:    found,
+				})
+			}
+		}
+	}
+	return mismatches
+}
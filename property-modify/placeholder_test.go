@@ -0,0 +1,79 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"empty value", "", nil},
+		{"none", "Hello", nil},
+		{"message format", "{1} of {0,number,#}", []string{"{0}", "{1}"}},
+		{"printf", "%s has %1$d items, %.2f%%", []string{"%.2f", "%1$d", "%s"}},
+		{"flags", "%-5s %,d %05d", []string{"%,d", "%-5s", "%05d"}},
+		{"percent sign", "50% off", nil},
+		{"percent at end", "up to 100%", nil},
+		{"percent before word", "50% discount", nil},
+		{"escaped percent", "100%% sure", nil},
+		{"unknown verb", "%y", nil},
+		{"non-ASCII", "Größe: %s", []string{"%s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Placeholders(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Placeholders(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	tests := []struct {
+		name     string
+		base, de string
+		wantKeys []string
+	}{
+		{"consistent", "hello=Hello {0}\n", "hello=Hallo {0}\n", nil},
+		{"missing", "hello=Hello {0}\n", "hello=Hallo\n", []string{"hello"}},
+		{"reordered", "range={0} to {1}\n", "range={1} bis {0}\n", nil},
+		{"format details", "count={0,number} items\n", "count={0} Stück\n", nil},
+		{"percent sign", "sale=50% off\n", "sale=50% Rabatt\n", nil},
+		{"empty value", "hello=Hello {0}\n", "hello=\n", []string{"hello"}},
+		{"extra key", "hello=Hello\n", "hello=Hallo\nbye=Tschüss {0}\n", nil},
+		{"disabled line", "hello=Hello {0}\n", "#hello=Hallo\nhello=Hallo {0}\n", nil},
+		{"duplicate key", "hello=Hello {0}\n", "hello=Hallo\nhello=Hallo {0}\n", []string{"hello"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			base := filepath.Join(dir, "messages"+PROPERTIES_EXT)
+			if err := os.WriteFile(base, []byte(tt.base), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "messages_de"+PROPERTIES_EXT), []byte(tt.de), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			b, err := LoadBundle(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, m := range b.CheckPlaceholders() {
+				if m.Locale != "de" {
+					t.Errorf("mismatch in locale %q, want de", m.Locale)
+				}
+				keys = append(keys, m.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("CheckPlaceholders keys = %q, want %q", keys, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package gpm
+
+import "fmt"
+
+// Provenance is where the value of a key is set: a file and the 1-based
+// line of the key in it.
+type Provenance struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line"`
+}
+
+func (p Provenance) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("line %d", p.Line)
+	}
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// SetSource sets the file the lines of m come from, as reported by
+// Explain. Load sets it.
+func (m *Modifier) SetSource(file string) {
+	m.source = file
+}
+
+// Source returns the file the lines of m come from.
+func (m *Modifier) Source() string {
+	return m.source
+}
+
+// SetPropertyFrom sets key to value like SetProperty, recording that the
+// value comes from another file, e.g. a layer of defaults, for Explain.
+func (m *Modifier) SetPropertyFrom(key, value string, from Provenance) error {
+	if err := m.SetProperty(key, value, nil); err != nil {
+		return err
+	}
+	m.index[key].origin = &from
+	return nil
+}
+
+// Explain returns where the value of key is set: where SetPropertyFrom
+// copied it from, or its line in the source of m.
+func (m *Modifier) Explain(key string) (Provenance, bool) {
+	e, ok := m.index[key]
+	if !ok {
+		return Provenance{}, false
+	}
+	if e.origin != nil {
+		return *e.origin, true
+	}
+	line := 1
+	for other := m.head; other != nil; other = other.next {
+		if other == e {
+			break
+		}
+		line += other.Lines()
+	}
+	return Provenance{File: m.source, Line: line}, true
+}
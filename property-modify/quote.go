@@ -0,0 +1,54 @@
+package gpm
+
+import "strings"
+
+// The quotes WithQuotedValues understands.
+const (
+	DOUBLE_QUOTE = '"'
+	SINGLE_QUOTE = '\''
+)
+
+// WithQuotedValues reads a value between single or double quotes, e.g.
+// key = "  padded  ", as the text between them, whitespace included, and
+// saving writes the quotes back. Without it, like in Java, the quotes are
+// part of the value and the whitespace around it is lost.
+func WithQuotedValues() ParserOption {
+	return func(p *Parser) {
+		p.quotedValues = true
+	}
+}
+
+// SetQuotedValues makes SetProperty quote with '"' the values that would
+// not be read back as they are WithQuotedValues: with whitespace at
+// either end, or between quotes themselves. Parse sets it
+// WithQuotedValues. Quoted values stay quoted either way.
+func (m *Modifier) SetQuotedValues(quoted bool) {
+	m.quotedValues = quoted
+}
+
+// Quote returns the quote around the value, "" if it isn't quoted.
+func (p *Property) Quote() string {
+	return p.quote
+}
+
+// unquote returns value without the quotes around it, and the quote.
+func unquote(value string) (string, string) {
+	if len(value) < 2 {
+		return value, ""
+	}
+	q := value[0]
+	if (q != DOUBLE_QUOTE && q != SINGLE_QUOTE) || value[len(value)-1] != q {
+		return value, ""
+	}
+	return value[1 : len(value)-1], string(q)
+}
+
+// needsQuotes reports whether value must be quoted to be read back as it
+// is WithQuotedValues.
+func needsQuotes(value string) bool {
+	if strings.TrimSpace(value) != value {
+		return true
+	}
+	_, quote := unquote(value)
+	return quote != ""
+}
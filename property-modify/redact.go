@@ -0,0 +1,35 @@
+package gpm
+
+// Redact replaces the values of the properties annotated with @secret or
+// for which secret returns true by placeholder, and returns their keys.
+// The commented-out properties, see Disabled, are redacted too, their
+// comment line keeping the rest of its text. secret may be nil.
+func (m *Modifier) Redact(secret func(p *Property) bool, placeholder string) []string {
+	isSecret := func(p *Property) bool {
+		return p.Annotations().Has(ANNOTATION_SECRET) || (secret != nil && secret(p))
+	}
+	var keys []string
+	for e := m.head; e != nil; e = e.next {
+		p := &e.Property
+		if p.key == "" {
+			prop, ok := p.disabled()
+			if !ok {
+				continue
+			}
+			prop.doc = p.doc
+			if !isSecret(&prop) {
+				continue
+			}
+			prop.value = placeholder
+			p.comment = prop.String()
+			keys = append(keys, prop.key)
+			continue
+		}
+		if !isSecret(p) {
+			continue
+		}
+		p.value = placeholder
+		keys = append(keys, p.key)
+	}
+	return keys
+}
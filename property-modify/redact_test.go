@@ -0,0 +1,42 @@
+package gpm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	password := func(p *Property) bool { return strings.HasSuffix(p.Key(), ".password") }
+	tests := []struct {
+		name     string
+		input    string
+		secret   func(p *Property) bool
+		want     string
+		wantKeys []string
+	}{
+		{"key", "signing.password=hunter2\nuser=me\n", password, "signing.password=<redacted>\nuser=me\n", []string{"signing.password"}},
+		{"annotation", "# @secret\ntoken=abc\n", nil, "# @secret\ntoken=<redacted>\n", []string{"token"}},
+		{"commented out", "#signing.password=hunter2\n", password, "#signing.password=<redacted>\n", []string{"signing.password"}},
+		{"disabled by gpm", "#signing.password=hunter2  # disabled by gpm\n", password, "#signing.password=<redacted>  # disabled by gpm\n", []string{"signing.password"}},
+		{"commented out with spaces", "# signing.password = hunter2\n", password, "# signing.password = <redacted>\n", []string{"signing.password"}},
+		{"plain comment", "# the password is not here\n", password, "# the password is not here\n", nil},
+		{"empty value", "signing.password=\n", password, "signing.password=<redacted>\n", []string{"signing.password"}},
+		{"duplicate keys", "signing.password=a\nsigning.password=b\n", password, "signing.password=<redacted>\nsigning.password=<redacted>\n", []string{"signing.password", "signing.password"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := doc.Redact(tt.secret, "<redacted>")
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Redact returned %q, want %q", keys, tt.wantKeys)
+			}
+			if got := doc.Text(); got != tt.want {
+				t.Errorf("redacted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
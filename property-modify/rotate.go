@@ -0,0 +1,102 @@
+package gpm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ANNOTATION_ROTATE is how often a secret value must be replaced, "@rotate
+// 90d". The last rotation is the @modified annotation of the key.
+const ANNOTATION_ROTATE = "rotate"
+
+// ParseRotationPeriod parses the period of @rotate: a number of days or
+// weeks, "90d" or "12w", or a time.ParseDuration duration.
+func ParseRotationPeriod(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count <= 0 {
+				break
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid @rotate period %q, expected e.g. 90d, 12w or 720h", s)
+	}
+	return d, nil
+}
+
+// RotationDue returns when the value of p must be rotated next, the
+// @modified time plus the @rotate period. ok is false if p has no @rotate
+// annotation, and due is the zero time if it was never rotated.
+func (p *Property) RotationDue() (due time.Time, ok bool, err error) {
+	v, ok := p.Annotations().Get(ANNOTATION_ROTATE)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	period, err := ParseRotationPeriod(v)
+	if err != nil {
+		return time.Time{}, true, err
+	}
+	last, rotated, err := p.Modified()
+	if err != nil || !rotated {
+		return time.Time{}, true, err
+	}
+	return last.Add(period), true, nil
+}
+
+// SetAnnotation sets the annotation name of key to value where it is
+// found, in the inline comment or a comment line directly above the key,
+// or adds it to the inline comment.
+func (m *Modifier) SetAnnotation(key, name, value string) error {
+	e, ok := m.index[key]
+	if !ok {
+		return fmt.Errorf("key %q not found", key)
+	}
+	if e.hasComment {
+		if comment, ok := replaceAnnotation(e.comment, name, value); ok {
+			e.comment = comment
+			return nil
+		}
+	}
+	for _, c := range m.commentBlock(e) {
+		if comment, ok := replaceAnnotation(c.comment, name, value); ok {
+			c.comment = comment
+			lines := strings.Split(e.doc, "\n")
+			for i, line := range lines {
+				lines[i], _ = replaceAnnotation(line, name, value)
+			}
+			e.doc = strings.Join(lines, "\n")
+			return nil
+		}
+	}
+	annotation := string(ANNOTATION) + name + " " + value
+	if !e.hasComment {
+		e.marker = m.commentPrefix
+		e.hasComment = true
+	}
+	e.comment = strings.TrimSpace(e.comment + " " + annotation)
+	return nil
+}
+
+// replaceAnnotation returns comment with the value of the annotation name
+// replaced, false if it has none.
+func replaceAnnotation(comment, name, value string) (string, bool) {
+	words := strings.Fields(comment)
+	for i, word := range words {
+		if word != string(ANNOTATION)+name {
+			continue
+		}
+		end := i + 1
+		for end < len(words) && !(len(words[end]) > 1 && words[end][0] == ANNOTATION) {
+			end++
+		}
+		words = append(words[:i+1], append(strings.Fields(value), words[end:]...)...)
+		return strings.Join(words, " "), true
+	}
+	return comment, false
+}
@@ -0,0 +1,23 @@
+package gpm
+
+import (
+	"fmt"
+)
+
+// Snapshot records the current properties under name, replacing an
+// earlier snapshot of the same name.
+func (m *Modifier) Snapshot(name string) {
+	if m.snapshots == nil {
+		m.snapshots = make(map[string][]Property)
+	}
+	m.snapshots[name] = m.lines()
+}
+
+// DiffSnapshot compares the snapshot name with the current properties.
+func (m *Modifier) DiffSnapshot(name string) ([]Change, error) {
+	props, ok := m.snapshots[name]
+	if !ok {
+		return nil, fmt.Errorf("snapshot %q not found", name)
+	}
+	return DiffProperties(props, m.lines()), nil
+}
@@ -0,0 +1,134 @@
+package gpm
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Storage is where LoadFrom, SaveTo and UpdateIn read and write property
+// files, by name. FileStorage is the local file system Load, Save and
+// UpdateFile use, MemoryStorage keeps files in memory for tests, and the
+// gpm/storage package has network backends.
+type Storage interface {
+	// Read returns the content of the file, an error wrapping
+	// fs.ErrNotExist if there is none.
+	Read(name string) ([]byte, error)
+	// Write replaces the content of the file, atomically where the
+	// backend allows it. perm is for backends that have permissions.
+	Write(name string, data []byte, perm fs.FileMode) error
+	// Stat describes the file, an error wrapping fs.ErrNotExist if
+	// there is none.
+	Stat(name string) (fs.FileInfo, error)
+	// Lock keeps other writers of the file out until unlock is called,
+	// waiting up to timeout for the current holder.
+	Lock(name string, timeout time.Duration) (unlock func(), err error)
+}
+
+// FileStorage is the local file system. Writes are atomic and locks are
+// lock files next to the locked file, see UpdateFile.
+type FileStorage struct{}
+
+func (FileStorage) Read(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (FileStorage) Write(name string, data []byte, perm fs.FileMode) error {
+	return writeFileAtomic(name, perm, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+func (FileStorage) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (FileStorage) Lock(name string, timeout time.Duration) (func(), error) {
+	return lockFile(name+LOCK_EXT, timeout)
+}
+
+// MemoryStorage keeps files in memory. It is safe for concurrent use.
+type MemoryStorage struct {
+	mu     sync.Mutex
+	files  map[string]memoryFile
+	locked map[string]bool
+}
+
+type memoryFile struct {
+	data    []byte
+	perm    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemoryStorage returns an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files:  make(map[string]memoryFile),
+		locked: make(map[string]bool),
+	}
+}
+
+func (s *MemoryStorage) Read(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (s *MemoryStorage) Write(name string, data []byte, perm fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = memoryFile{data: append([]byte(nil), data...), perm: perm, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Stat(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryFileInfo{name: path.Base(name), file: f}, nil
+}
+
+func (s *MemoryStorage) Lock(name string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		if !s.locked[name] {
+			s.locked[name] = true
+			s.mu.Unlock()
+			return func() {
+				s.mu.Lock()
+				delete(s.locked, name)
+				s.mu.Unlock()
+			}, nil
+		}
+		s.mu.Unlock()
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// memoryFileInfo describes a file of a MemoryStorage.
+type memoryFileInfo struct {
+	name string
+	file memoryFile
+}
+
+func (i memoryFileInfo) Name() string       { return i.name }
+func (i memoryFileInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memoryFileInfo) Mode() fs.FileMode  { return i.file.perm }
+func (i memoryFileInfo) ModTime() time.Time { return i.file.modTime }
+func (i memoryFileInfo) IsDir() bool        { return false }
+func (i memoryFileInfo) Sys() any           { return nil }
@@ -0,0 +1,162 @@
+// Package storage has the network backends of gpm.Storage, kept out of
+// the root package so that it never uses the network: HTTP, for servers
+// answering GET, HEAD and PUT like gpm serve or WebDAV, and S3, for S3
+// compatible object storage.
+//
+// Neither has locks. Instead, a file written after it was read is only
+// replaced if it didn't change in between, with If-Match and the ETag of
+// the read, and the write fails otherwise.
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP reads and writes files by URL.
+type HTTP struct {
+	client *http.Client
+	token  string
+	etags  *etags
+}
+
+// NewHTTP returns an HTTP storage sending token, if not empty, as an
+// "Authorization: Bearer" header.
+func NewHTTP(token string) *HTTP {
+	return &HTTP{
+		client: &http.Client{Timeout: 30 * time.Second},
+		token:  token,
+		etags:  newETags(),
+	}
+}
+
+func (s *HTTP) do(method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	if method == http.MethodPut {
+		s.etags.ifMatch(req, url)
+	}
+	return s.client.Do(req)
+}
+
+func (s *HTTP) Read(url string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := statusError("read", url, resp); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.etags.record(url, resp)
+	return data, nil
+}
+
+func (s *HTTP) Write(url string, data []byte, perm fs.FileMode) error {
+	resp, err := s.do(http.MethodPut, url, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := statusError("write", url, resp); err != nil {
+		return err
+	}
+	s.etags.record(url, resp)
+	return nil
+}
+
+func (s *HTTP) Stat(url string) (fs.FileInfo, error) {
+	resp, err := s.do(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := statusError("stat", url, resp); err != nil {
+		return nil, err
+	}
+	return infoOf(url, resp), nil
+}
+
+// Lock does nothing, see the package documentation.
+func (s *HTTP) Lock(url string, timeout time.Duration) (func(), error) {
+	return func() {}, nil
+}
+
+// etags are the ETags of the files read or written, sent back with
+// If-Match when they are written.
+type etags struct {
+	mu   sync.Mutex
+	tags map[string]string
+}
+
+func newETags() *etags {
+	return &etags{tags: make(map[string]string)}
+}
+
+func (e *etags) record(name string, resp *http.Response) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if tag := resp.Header.Get("ETag"); tag != "" {
+		e.tags[name] = tag
+	}
+}
+
+func (e *etags) ifMatch(req *http.Request, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if tag, ok := e.tags[name]; ok {
+		req.Header.Set("If-Match", tag)
+	}
+}
+
+// statusError returns the error of a response that isn't a success.
+func statusError(op, name string, resp *http.Response) error {
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return fmt.Errorf("%s changed since it was read", name)
+	}
+	return fmt.Errorf("%s %s: %s", op, name, resp.Status)
+}
+
+// fileInfo describes a file from the headers of a response.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func infoOf(name string, resp *http.Response) fileInfo {
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return fileInfo{
+		name:    path.Base(strings.TrimRight(name, "/")),
+		size:    resp.ContentLength,
+		modTime: modTime,
+	}
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return 0o644 }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return false }
+func (i fileInfo) Sys() any           { return nil }
@@ -0,0 +1,57 @@
+package gpm
+
+import (
+	"strings"
+	"time"
+)
+
+// STORE_DATE_LAYOUT is the format of the timestamp comment that
+// java.util.Properties.store() writes at the top of a file, Java's
+// Date.toString().
+const STORE_DATE_LAYOUT = "Mon Jan 02 15:04:05 MST 2006"
+
+// IsStoreTimestamp reports whether a comment is a store() timestamp.
+func IsStoreTimestamp(comment string) bool {
+	_, err := time.Parse(STORE_DATE_LAYOUT, strings.TrimSpace(comment))
+	return err == nil
+}
+
+// StoreTimestamp returns the 1-based line of the store() timestamp among
+// the comment lines at the top of the file.
+func (m *Modifier) StoreTimestamp() (int, bool) {
+	for i, p := range m.lines() {
+		if !p.IsCommentOnly() {
+			break
+		}
+		if IsStoreTimestamp(p.comment) {
+			return i + 1, true
+		}
+	}
+	return NO_LINE, false
+}
+
+// RemoveStoreTimestamp removes the store() timestamp line, if any.
+func (m *Modifier) RemoveStoreTimestamp() bool {
+	line, ok := m.StoreTimestamp()
+	if !ok {
+		return false
+	}
+	m.remove(m.at(line))
+	return true
+}
+
+// RefreshStoreTimestamp sets the store() timestamp to now. If there is
+// none, it is inserted after the comment lines at the top, where store()
+// writes it.
+func (m *Modifier) RefreshStoreTimestamp(now time.Time) {
+	stamp := now.Format(STORE_DATE_LAYOUT)
+	if line, ok := m.StoreTimestamp(); ok {
+		m.at(line).comment = stamp
+		return
+	}
+	mark := m.head
+	for mark != nil && mark.IsCommentOnly() {
+		mark = mark.next
+	}
+	m.insertBefore(mark, Property{comment: stamp, hasComment: true, tightComment: true})
+}
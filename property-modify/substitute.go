@@ -0,0 +1,43 @@
+package gpm
+
+import (
+	"strings"
+)
+
+// Substitute replaces ${name} in keys and values by vars[name]. Names
+// that are not in vars are left alone. It returns the number of
+// properties that changed.
+func (m *Modifier) Substitute(vars map[string]string) int {
+	changed := 0
+	lines := m.lines()
+	for i, p := range lines {
+		if p.key == "" {
+			continue
+		}
+		key := SubstituteString(p.key, vars)
+		value := SubstituteString(p.value, vars)
+		if key != p.key || value != p.value {
+			lines[i].key = key
+			lines[i].value = value
+			changed++
+		}
+	}
+	if changed > 0 {
+		m.setLines(lines)
+	}
+	return changed
+}
+
+// SubstituteString replaces ${name} in s by vars[name], names that are
+// not in vars are left alone.
+func SubstituteString(s string, vars map[string]string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return referenceRe.ReplaceAllStringFunc(s, func(ref string) string {
+		if v, ok := vars[ref[2:len(ref)-1]]; ok {
+			return v
+		}
+		return ref
+	})
+}
@@ -0,0 +1,159 @@
+package gpm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The typed getters of Parser and Modifier return the value of a key
+// converted to a Go type, false if the key isn't set, and an error naming
+// the key if its value can't be converted. Surrounding whitespace is
+// ignored.
+
+// Get returns the value of key after the last parse, set by its last line
+// like in Java.
+func (p *Parser) Get(key string) (string, bool) {
+	for i := len(p.props) - 1; i >= 0; i-- {
+		if p.props[i].key == key {
+			return p.props[i].value, true
+		}
+	}
+	return "", false
+}
+
+// GetInt is Modifier.GetInt after the last parse.
+func (p *Parser) GetInt(key string) (int, bool, error) {
+	return getInt(p.read, key)
+}
+
+// GetInt64 is Modifier.GetInt64 after the last parse.
+func (p *Parser) GetInt64(key string) (int64, bool, error) {
+	return getInt64(p.read, key)
+}
+
+// GetBool is Modifier.GetBool after the last parse.
+func (p *Parser) GetBool(key string) (bool, bool, error) {
+	return getBool(p.read, key)
+}
+
+// GetFloat is Modifier.GetFloat after the last parse.
+func (p *Parser) GetFloat(key string) (float64, bool, error) {
+	return getFloat(p.read, key)
+}
+
+// GetDuration is Modifier.GetDuration after the last parse.
+func (p *Parser) GetDuration(key string) (time.Duration, bool, error) {
+	return getDuration(p.read, key)
+}
+
+// GetStringSlice is Modifier.GetStringSlice after the last parse.
+func (p *Parser) GetStringSlice(key, sep string) ([]string, bool, error) {
+	return getStringSlice(p.read, key, sep)
+}
+
+// GetInt returns the value of key as a decimal int, e.g. versionCode.
+func (m *Modifier) GetInt(key string) (int, bool, error) {
+	return getInt(m.read, key)
+}
+
+// GetInt64 returns the value of key as a decimal int64.
+func (m *Modifier) GetInt64(key string) (int64, bool, error) {
+	return getInt64(m.read, key)
+}
+
+// GetBool returns the value of key as a bool, see strconv.ParseBool, e.g.
+// "true", "false", "1" or "0".
+func (m *Modifier) GetBool(key string) (bool, bool, error) {
+	return getBool(m.read, key)
+}
+
+// GetFloat returns the value of key as a float64.
+func (m *Modifier) GetFloat(key string) (float64, bool, error) {
+	return getFloat(m.read, key)
+}
+
+// GetDuration returns the value of key as a duration, see
+// time.ParseDuration, e.g. "1m30s".
+func (m *Modifier) GetDuration(key string) (time.Duration, bool, error) {
+	return getDuration(m.read, key)
+}
+
+// GetStringSlice returns the value of key split at sep, without the
+// whitespace around the elements and the empty ones, e.g. the JVM
+// arguments of org.gradle.jvmargs with " ".
+func (m *Modifier) GetStringSlice(key, sep string) ([]string, bool, error) {
+	return getStringSlice(m.read, key, sep)
+}
+
+// read is Get, recording the read in the Usage of the parser.
+func (p *Parser) read(key string) (string, bool) {
+	value, ok := p.Get(key)
+	p.usage.record(key, ok)
+	return value, ok
+}
+
+// read is Get, recording the read in the Usage of the modifier.
+func (m *Modifier) read(key string) (string, bool) {
+	value, ok := m.Get(key)
+	m.usage.record(key, ok)
+	return value, ok
+}
+
+// getter is the Get of a Parser or Modifier.
+type getter func(key string) (string, bool)
+
+// convert returns the value of key converted by parse.
+func convert[T any](get getter, key string, parse func(string) (T, error)) (T, bool, error) {
+	var zero T
+	value, ok := get(key)
+	if !ok {
+		return zero, false, nil
+	}
+	v, err := parse(strings.TrimSpace(value))
+	if err != nil {
+		return zero, true, fmt.Errorf("%s: %w", key, err)
+	}
+	return v, true, nil
+}
+
+func getInt(get getter, key string) (int, bool, error) {
+	return convert(get, key, strconv.Atoi)
+}
+
+func getInt64(get getter, key string) (int64, bool, error) {
+	return convert(get, key, func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+func getBool(get getter, key string) (bool, bool, error) {
+	return convert(get, key, strconv.ParseBool)
+}
+
+func getFloat(get getter, key string) (float64, bool, error) {
+	return convert(get, key, func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+func getDuration(get getter, key string) (time.Duration, bool, error) {
+	return convert(get, key, time.ParseDuration)
+}
+
+func getStringSlice(get getter, key, sep string) ([]string, bool, error) {
+	return convert(get, key, func(s string) ([]string, error) {
+		if sep == "" {
+			return nil, errors.New("empty separator")
+		}
+		var elems []string
+		for _, elem := range strings.Split(s, sep) {
+			if elem = strings.TrimSpace(elem); elem != "" {
+				elems = append(elems, elem)
+			}
+		}
+		return elems, nil
+	})
+}
@@ -0,0 +1,154 @@
+package gpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LOCK_EXT is appended to the path of a file to name its lock file.
+const LOCK_EXT = ".lock"
+
+// UpdateOption configures UpdateFile.
+type UpdateOption func(*updateConfig)
+
+type updateConfig struct {
+	parserOpts  []ParserOption
+	saveOpts    []SaveOption
+	backup      string
+	lockTimeout time.Duration
+}
+
+// WithParserOptions parses the file with opts, e.g. the ones of the gpm
+// command, after WithDecoding(ENCODING_AUTO).
+func WithParserOptions(opts ...ParserOption) UpdateOption {
+	return func(c *updateConfig) {
+		c.parserOpts = append(c.parserOpts, opts...)
+	}
+}
+
+// WithSaveOptions passes opts to Save.
+func WithSaveOptions(opts ...SaveOption) UpdateOption {
+	return func(c *updateConfig) {
+		c.saveOpts = append(c.saveOpts, opts...)
+	}
+}
+
+// WithBackup keeps the previous content of the file next to it, at its
+// path with suffix appended, e.g. ".bak".
+func WithBackup(suffix string) UpdateOption {
+	return func(c *updateConfig) {
+		c.backup = suffix
+	}
+}
+
+// WithLockTimeout sets how long to wait for another process holding the
+// lock of the file, 10 seconds by default.
+func WithLockTimeout(timeout time.Duration) UpdateOption {
+	return func(c *updateConfig) {
+		c.lockTimeout = timeout
+	}
+}
+
+// UpdateFile parses the property file at path, calls fn to modify it and
+// atomically replaces the file with the result, keeping its permissions
+// and its encoding, see ENCODING_AUTO.
+// Concurrent UpdateFile calls, also from other processes, are serialized
+// with a lock file next to it. If fn returns an error nothing is written
+// and the error is returned.
+func UpdateFile(path string, fn func(*Modifier) error, opts ...UpdateOption) error {
+	return UpdateIn(FileStorage{}, path, fn, opts...)
+}
+
+// UpdateIn is UpdateFile for the file name of s, serialized with
+// s.Lock.
+func UpdateIn(s Storage, name string, fn func(*Modifier) error, opts ...UpdateOption) error {
+	cfg := updateConfig{lockTimeout: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	unlock, err := s.Lock(name, cfg.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+	data, err := s.Read(name)
+	if err != nil {
+		return err
+	}
+	parserOpts := append([]ParserOption{WithDecoding(ENCODING_AUTO)}, cfg.parserOpts...)
+	doc, err := Parse(bytes.NewReader(data), parserOpts...)
+	if err != nil {
+		return err
+	}
+	if err := fn(doc.Modifier); err != nil {
+		return err
+	}
+
+	perm := info.Mode().Perm()
+	if cfg.backup != "" {
+		if err := s.Write(name+cfg.backup, data, perm); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := doc.Save(&buf, cfg.saveOpts...); err != nil {
+		return err
+	}
+	return s.Write(name, buf.Bytes(), perm)
+}
+
+// writeFileAtomic writes a temporary file with write and renames it to
+// path.
+func writeFileAtomic(path string, perm os.FileMode, write func(f *os.File) error) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// the umask may have dropped bits of perm
+		err = os.Chmod(tmp, perm)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lockFile creates the lock file at path, waiting up to timeout for
+// another holder to remove it, and returns the function removing it.
+func lockFile(path string, timeout time.Duration) (unlock func(), err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another process, remove the lock file if it is stale", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
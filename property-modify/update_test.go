@@ -0,0 +1,76 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateFileKeepsEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"latin1", "greeting=caf\xe9\n", "greeting=caf\xe9\nadded=\xe9t\xe9\n"},
+		{"utf8", "greeting=café\n", "greeting=café\nadded=été\n"},
+		{"ascii", "greeting=cafe\n", "greeting=cafe\nadded=été\n"},
+		{"empty", "", "added=été\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gradle.properties")
+			if err := os.WriteFile(path, []byte(tt.input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := UpdateFile(path, func(m *Modifier) error {
+				return m.SetProperty("added", "été", nil)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("saved %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateFileParserOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []UpdateOption
+		input string
+		want  string
+	}{
+		{"java separators", []UpdateOption{WithParserOptions(WithJavaSeparators())}, "a: 1\nb 2\n", "a: 10\nb 2\n"},
+		{"comment prefixes", []UpdateOption{WithParserOptions(WithCommentPrefixes(";"))}, "; note\na=1 ; one\n", "; note\na=10 ; one\n"},
+		{"forced encoding", []UpdateOption{WithParserOptions(WithDecoding(ENCODING_UTF8))}, "a=1\nb=caf\xe9\n", "a=10\nb=caf\ufffd\n"},
+		{"duplicate keys", []UpdateOption{WithParserOptions(WithDuplicates(DUPLICATES_KEEP_LAST))}, "a=1\na=2\n", "a=10\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gradle.properties")
+			if err := os.WriteFile(path, []byte(tt.input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := UpdateFile(path, func(m *Modifier) error {
+				return m.SetProperty("a", "10", nil)
+			}, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("saved %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package gpm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Usage counts the keys read through the typed getters of the parsers and
+// modifiers it is given to, see WithUsage and Modifier.SetUsage, e.g. in
+// a daemon reading its configuration, to find the properties it never
+// uses. It is safe for concurrent use.
+type Usage struct {
+	mu sync.Mutex
+	// reads counts the reads of every key, missing the reads of the keys
+	// that weren't set
+	reads   map[string]int
+	missing map[string]int
+	onRead  func(key string, found bool)
+}
+
+// UsageReport is what a Usage recorded, compared with the keys of a file.
+type UsageReport struct {
+	// Reads counts the reads of every key read, set or not
+	Reads map[string]int `json:"reads"`
+	// Missing are the keys read but not set
+	Missing []string `json:"missing"`
+	// Unread are the keys set but never read
+	Unread []string `json:"unread"`
+}
+
+// NewUsage returns a Usage that recorded nothing yet.
+func NewUsage() *Usage {
+	return &Usage{
+		reads:   make(map[string]int),
+		missing: make(map[string]int),
+	}
+}
+
+// WithUsage records the keys read through the typed getters of the Parser
+// and of the Document Parse returns in u.
+func WithUsage(u *Usage) ParserOption {
+	return func(p *Parser) {
+		p.usage = u
+	}
+}
+
+// SetUsage records the keys read through the typed getters in u, nil
+// recording nothing.
+func (m *Modifier) SetUsage(u *Usage) {
+	m.usage = u
+}
+
+// OnRead calls fn after every read, with whether the key was set. fn is
+// called without u locked, and must be safe for concurrent use if the
+// getters are.
+func (u *Usage) OnRead(fn func(key string, found bool)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.onRead = fn
+}
+
+// record counts a read of key.
+func (u *Usage) record(key string, found bool) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.reads[key]++
+	if !found {
+		u.missing[key]++
+	}
+	onRead := u.onRead
+	u.mu.Unlock()
+	if onRead != nil {
+		onRead(key, found)
+	}
+}
+
+// Reads returns how many times key was read.
+func (u *Usage) Reads(key string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.reads[key]
+}
+
+// Reset forgets every read.
+func (u *Usage) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	clear(u.reads)
+	clear(u.missing)
+}
+
+// Report compares the reads with keys, the keys of the file read, e.g.
+// Document.Keys. A key read while it wasn't set is missing only if it
+// isn't in keys, since it may have been added since.
+func (u *Usage) Report(keys []string) UsageReport {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	present := make(map[string]bool, len(keys))
+	report := UsageReport{Reads: make(map[string]int, len(u.reads)), Missing: []string{}, Unread: []string{}}
+	for _, key := range keys {
+		if present[key] {
+			continue
+		}
+		present[key] = true
+		if u.reads[key] == 0 {
+			report.Unread = append(report.Unread, key)
+		}
+	}
+	for key, n := range u.reads {
+		report.Reads[key] = n
+	}
+	for key := range u.missing {
+		if !present[key] {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unread)
+	return report
+}
+
+// LintUnread reports the properties of props a program never read, by the
+// reads of its report.
+func LintUnread(props []Property, report UsageReport) []LintIssue {
+	var issues []LintIssue
+	for _, p := range props {
+		if p.key == "" || report.Reads[p.key] > 0 {
+			continue
+		}
+		issues = append(issues, LintIssue{
+			Line:    p.lineNum,
+			Rule:    RULE_UNREAD,
+			Message: fmt.Sprintf("%s is never read", p.key),
+		})
+	}
+	return issues
+}
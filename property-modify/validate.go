@@ -0,0 +1,116 @@
+package gpm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Annotations understood by Validate.
+const (
+	ANNOTATION_TYPE    = "type"
+	ANNOTATION_MIN     = "min"
+	ANNOTATION_MAX     = "max"
+	ANNOTATION_PATTERN = "pattern"
+	ANNOTATION_ENUM    = "enum"
+	ANNOTATION_SECRET  = "secret"
+)
+
+// ValidationError describes a property whose value doesn't satisfy its
+// annotations.
+type ValidationError struct {
+	Key     string
+	Line    int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Key, e.Message)
+}
+
+// Validate checks every property against its annotations:
+//
+//	@type int|float|bool|string
+//	@min n, @max n  numeric bounds, for int and float
+//	@pattern re     the whole value must match the regular expression
+//	@enum a|b|c     the value must be one of the listed ones
+//
+// and against the constraints set by SetConstraints.
+func (m *Modifier) Validate() []*ValidationError {
+	var errs []*ValidationError
+	for _, p := range m.lines() {
+		if p.key == "" {
+			continue
+		}
+		msg := validateProperty(&p)
+		if err := m.constraints.Check(p.key, p.value); msg == "" && err != nil {
+			msg = err.message()
+		}
+		if msg != "" {
+			errs = append(errs, &ValidationError{
+				Key:     p.key,
+				Line:    p.lineNum,
+				Message: msg,
+			})
+		}
+	}
+	return errs
+}
+
+func validateProperty(p *Property) string {
+	a := p.Annotations()
+	if len(a) == 0 {
+		return ""
+	}
+
+	typ, _ := a.Get(ANNOTATION_TYPE)
+	switch typ {
+	case "", "string":
+	case "int", "float":
+		n, err := strconv.ParseFloat(p.value, 64)
+		if err != nil || (typ == "int" && strings.ContainsAny(p.value, ".eE")) {
+			return fmt.Sprintf("%q is not a valid %s", p.value, typ)
+		}
+		if min, ok := a.Get(ANNOTATION_MIN); ok {
+			if bound, err := strconv.ParseFloat(min, 64); err == nil && n < bound {
+				return fmt.Sprintf("%s is less than %s", p.value, min)
+			}
+		}
+		if max, ok := a.Get(ANNOTATION_MAX); ok {
+			if bound, err := strconv.ParseFloat(max, 64); err == nil && n > bound {
+				return fmt.Sprintf("%s is greater than %s", p.value, max)
+			}
+		}
+	case "bool":
+		if _, err := strconv.ParseBool(p.value); err != nil {
+			return fmt.Sprintf("%q is not a valid bool", p.value)
+		}
+	default:
+		return fmt.Sprintf("unknown @type %q", typ)
+	}
+
+	if pattern, ok := a.Get(ANNOTATION_PATTERN); ok {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Sprintf("invalid @pattern: %v", err)
+		}
+		if !re.MatchString(p.value) {
+			return fmt.Sprintf("%q does not match %s", p.value, pattern)
+		}
+	}
+
+	if enum, ok := a.Get(ANNOTATION_ENUM); ok {
+		found := false
+		for _, v := range strings.Split(enum, "|") {
+			if strings.TrimSpace(v) == p.value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("%q is not one of %s", p.value, enum)
+		}
+	}
+	return ""
+}
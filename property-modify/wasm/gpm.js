@@ -0,0 +1,25 @@
+// Loads gpm.wasm and exposes the gpm core with exceptions instead of
+// {value, error} results. wasm_exec.js from the Go distribution must be
+// loaded first.
+//
+//   const gpm = await loadGpm("gpm.wasm");
+//   const text = gpm.apply(text, [{type: "set", key: "app.version", value: "1.0.1"}]);
+
+async function loadGpm(url) {
+  const go = new Go();
+  const { instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  go.run(instance);
+
+  function unwrap(result) {
+    if (result.error !== undefined) {
+      throw new Error(result.error);
+    }
+    return result.value;
+  }
+
+  return {
+    apply: (text, ops) => unwrap(gpmApply(text, JSON.stringify(ops))),
+    get: (text, key) => unwrap(gpmGet(text, key)),
+    ast: (text) => JSON.parse(unwrap(gpmAST(text))),
+  };
+}
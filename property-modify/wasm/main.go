@@ -0,0 +1,39 @@
+//go:build js && wasm
+
+// Command wasm builds the gpm core as a WebAssembly module for web tools:
+//
+//	GOOS=js GOARCH=wasm go build -o gpm.wasm ./wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// It registers gpmApply, gpmGet and gpmAST on the global object, which
+// gpm.js wraps. Each returns {value} or {error}.
+package main
+
+import (
+	"gpm/bridge"
+	"syscall/js"
+)
+
+func result(value any, err error) map[string]any {
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return map[string]any{"value": value}
+}
+
+func main() {
+	js.Global().Set("gpmApply", js.FuncOf(func(this js.Value, args []js.Value) any {
+		return result(bridge.Apply(args[0].String(), args[1].String()))
+	}))
+	js.Global().Set("gpmGet", js.FuncOf(func(this js.Value, args []js.Value) any {
+		value, ok, err := bridge.Get(args[0].String(), args[1].String())
+		if err == nil && !ok {
+			return result(nil, nil)
+		}
+		return result(value, err)
+	}))
+	js.Global().Set("gpmAST", js.FuncOf(func(this js.Value, args []js.Value) any {
+		return result(bridge.AST(args[0].String()))
+	}))
+	select {}
+}
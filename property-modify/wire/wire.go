@@ -0,0 +1,180 @@
+// Package wire defines the JSON documents printed by gpm for other
+// programs: -list, -diff, -dump-ast, -diagnostics json and capabilities
+// -json. Every document starts with the schema version and its kind.
+// Fields are only ever added within a schema version; renaming or
+// removing one bumps SCHEMA_VERSION.
+package wire
+
+import (
+	"encoding/json"
+	"gpm"
+	"io"
+)
+
+const SCHEMA_VERSION = 1
+
+const (
+	KIND_LIST         = "list"
+	KIND_DIFF         = "diff"
+	KIND_REPORT       = "report"
+	KIND_AST          = "ast"
+	KIND_CAPABILITIES = "capabilities"
+)
+
+// Header starts every document.
+type Header struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Kind          string `json:"kind"` // KIND_LIST, KIND_DIFF, KIND_REPORT, KIND_AST or KIND_CAPABILITIES
+}
+
+func header(kind string) Header {
+	return Header{SchemaVersion: SCHEMA_VERSION, Kind: kind}
+}
+
+// Property is a key of a file.
+type Property struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Comment string `json:"comment,omitempty"`
+	Line    int    `json:"line"`
+	// Provenance is set by -list -provenance
+	Provenance *gpm.Provenance `json:"provenance,omitempty"`
+	// Disabled is set on a commented-out property listed by -list
+	// -include-disabled
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// List is printed by -list: the properties of a file in file order.
+type List struct {
+	Header
+	Properties []Property `json:"properties"`
+}
+
+func NewList(props []gpm.Property) *List {
+	return newList(props, false)
+}
+
+// NewListIncludingDisabled is NewList with the commented-out properties
+// too, flagged Disabled, see gpm.Property.Disabled.
+func NewListIncludingDisabled(props []gpm.Property) *List {
+	return newList(props, true)
+}
+
+func newList(props []gpm.Property, includeDisabled bool) *List {
+	list := &List{Header: header(KIND_LIST), Properties: []Property{}}
+	for _, p := range props {
+		disabled := false
+		if p.Key() == "" {
+			if !includeDisabled {
+				continue
+			}
+			if p, disabled = p.Disabled(); !disabled {
+				continue
+			}
+		}
+		list.Properties = append(list.Properties, Property{
+			Key:      p.Key(),
+			Value:    p.Value(),
+			Comment:  p.Comment(),
+			Line:     p.LineNum(),
+			Disabled: disabled,
+		})
+	}
+	return list
+}
+
+// WithProvenance adds where the value of every key is set, see
+// gpm.Modifier.Explain.
+func (l *List) WithProvenance(m *gpm.Modifier) *List {
+	for i := range l.Properties {
+		if from, ok := m.Explain(l.Properties[i].Key); ok {
+			l.Properties[i].Provenance = &from
+		}
+	}
+	return l
+}
+
+// Change is the difference of one key.
+type Change struct {
+	Type     string `json:"type"` // "added", "removed" or "changed"
+	Key      string `json:"key"`
+	OldValue string `json:"old,omitempty"`
+	NewValue string `json:"new,omitempty"`
+}
+
+// Diff is printed by -diff: the keys changed by the operations.
+type Diff struct {
+	Header
+	Changes []Change `json:"changes"`
+}
+
+func NewDiff(changes []gpm.Change) *Diff {
+	diff := &Diff{Header: header(KIND_DIFF), Changes: []Change{}}
+	for _, c := range changes {
+		diff.Changes = append(diff.Changes, Change(c))
+	}
+	return diff
+}
+
+// Diagnostic is a lint issue or validation error.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Level   string `json:"level"` // "warning" or "error"
+	Message string `json:"message"`
+}
+
+// Report is printed by -lint and -validate with -diagnostics json.
+type Report struct {
+	Header
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func NewReport(diagnostics []Diagnostic) *Report {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return &Report{Header: header(KIND_REPORT), Diagnostics: diagnostics}
+}
+
+// AST is printed by -dump-ast json: the parsed model of every line.
+type AST struct {
+	Header
+	Entries []gpm.ASTEntry `json:"entries"`
+}
+
+func NewAST(entries []gpm.ASTEntry) *AST {
+	return &AST{Header: header(KIND_AST), Entries: entries}
+}
+
+// Write writes doc as indented JSON.
+func Write(w io.Writer, doc any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Capabilities is printed by capabilities -json: what the running binary
+// supports, which depends on its version and build tags, so that scripts
+// can detect features instead of comparing versions.
+type Capabilities struct {
+	Header
+	Version  string   `json:"version"`
+	Commands []string `json:"commands"`
+	// Dialects are the property file syntaxes read besides the default
+	Dialects []string `json:"dialects"`
+	// Encodings are the values of -encoding
+	Encodings []string `json:"encodings"`
+	// Converters are the other formats files are written in
+	Converters []string `json:"converters"`
+	// Resolvers are where values and files are read from and written to
+	Resolvers []string `json:"resolvers"`
+	// Server are the network features, empty in gpm_core builds
+	Server []string `json:"server"`
+}
+
+func NewCapabilities(c Capabilities) *Capabilities {
+	c.Header = header(KIND_CAPABILITIES)
+	return &c
+}
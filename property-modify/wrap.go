@@ -0,0 +1,76 @@
+package gpm
+
+import (
+	"strings"
+)
+
+const wrapIndent = "    "
+
+// wrapProperty renders p, splitting its value over several lines with
+// backslash continuations when the line is longer than column.
+func wrapProperty(p *Property, column int) string {
+	line := p.String()
+	if p.IsEmpty() || p.IsCommentOnly() || p.IsRaw() || len([]rune(line)) <= column {
+		return line
+	}
+
+	// tail is everything after the value, i.e. the inline comment
+	head, escaped, tail := p.parts()
+	value := []rune(escaped)
+	width := column - len([]rune(head)) - 1
+	if width < 1 || len(value) <= 1 {
+		// the key alone reaches the column, or nothing to wrap
+		return line
+	}
+	var sb strings.Builder
+	sb.WriteString(head)
+	for {
+		if len(value) <= width+1 {
+			break
+		}
+		at := wrapAt(value, width)
+		if at <= 0 || at >= len(value) {
+			break
+		}
+		sb.WriteString(string(value[:at]))
+		sb.WriteString("\\\n")
+		sb.WriteString(wrapIndent)
+		value = value[at:]
+		width = column - len(wrapIndent) - 1
+	}
+	sb.WriteString(string(value))
+	sb.WriteString(tail)
+	return sb.String()
+}
+
+// wrapAt finds the position to break value at, as close to width as
+// possible. A break must not split an escape sequence and must not leave
+// whitespace at the start of the continuation line, since readers strip
+// it. Returns -1 if there is no such position.
+func wrapAt(value []rune, width int) int {
+	if width < 1 {
+		width = 1
+	}
+	for at := width; at > 0; at-- {
+		if canWrapAt(value, at) {
+			return at
+		}
+	}
+	for at := width + 1; at < len(value); at++ {
+		if canWrapAt(value, at) {
+			return at
+		}
+	}
+	return -1
+}
+
+func canWrapAt(value []rune, at int) bool {
+	if at >= len(value) || isBlank(value[at]) {
+		return false
+	}
+	backslashes := 0
+	for i := at - 1; i >= 0 && value[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 0
+}
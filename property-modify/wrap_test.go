@@ -0,0 +1,86 @@
+package gpm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// saveWrapped parses input, applies change and saves it wrapped at column.
+func saveWrapped(t *testing.T, input string, column int, change func(*Document) error) string {
+	t.Helper()
+	doc, err := ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change != nil {
+		if err := change(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := doc.Save(&buf, WithWrap(column)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWrapUnchanged(t *testing.T) {
+	setEmpty := func(doc *Document) error { return doc.SetProperty("key", "", nil) }
+	tests := []struct {
+		name   string
+		input  string
+		column int
+		change func(*Document) error
+		want   string
+	}{
+		{"empty value after long key", "some.long.key.name=\n", 10, nil, "some.long.key.name=\n"},
+		{"one character after long key", "some.long.key.name=x\n", 10, nil, "some.long.key.name=x\n"},
+		{"value emptied before a comment", "key =   v#\n", 3, setEmpty, "key =#\n"},
+		{"empty value and comment", "key =   #note\n", 3, setEmpty, "key =   #note\n"},
+		{"comment line", "# " + strings.Repeat("long ", 9) + "long\n", 10, nil, "# " + strings.Repeat("long ", 9) + "long\n"},
+		{"duplicate keys", "a=1\na=2\n", 2, nil, "a=1\na=2\n"},
+		{"short line", "k=v # c\n", 20, nil, "k=v # c\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := saveWrapped(t, tt.input, tt.column, tt.change); got != tt.want {
+				t.Errorf("Save(%q) with WithWrap(%d) = %q, want %q", tt.input, tt.column, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapLongValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"words", "k=" + strings.Repeat("abcdef ", 10) + "\n"},
+		{"comment", "k=" + strings.Repeat("abcdef ", 10) + "# note\n"},
+		{"escapes", "k=" + strings.Repeat(`a\\b\=c `, 10) + "\n"},
+		{"no blanks", "k=" + strings.Repeat("x", 60) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := saveWrapped(t, tt.input, 20, nil)
+			reparsed, err := ParseString(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := doc.Get("k")
+			if got, _ := reparsed.Get("k"); got != want {
+				t.Errorf("wrapped value reads back as %q, want %q", got, want)
+			}
+			before, _ := doc.GetProperty("k")
+			after, _ := reparsed.GetProperty("k")
+			if after.Comment() != before.Comment() {
+				t.Errorf("wrapped comment reads back as %q, want %q", after.Comment(), before.Comment())
+			}
+		})
+	}
+}